@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tscholl2/prime/prime"
+)
+
+func countMain(args []string) {
+	fs := flag.NewFlagSet("count", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println(`prime count: count the primes p <= x, or lo <= p <= hi, and print to stdout
+Example: 'prime count 1e12' prints: 37607912018
+Example: 'prime count 100 200' prints: 21
+Numbers can be written as 1000000, 1e6 or 10^6
+Counting up to x needs memory proportional to sqrt(x), about 650 MB near 2^64
+Options:`)
+		fs.PrintDefaults()
+	}
+	var timeout time.Duration
+	fs.DurationVar(&timeout, "timeout", 0, "give up after this long, e.g. 30s [default: never]")
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return
+	}
+	var bounds []uint64
+	for _, s := range fs.Args() {
+		n, err := parseCount(s)
+		if err != nil {
+			log.Fatal(err)
+		}
+		bounds = append(bounds, n)
+	}
+	ctx, cancel := timeoutContext(timeout)
+	defer cancel()
+	hi, err := prime.CountContext(ctx, bounds[len(bounds)-1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(bounds) == 2 && bounds[0] > 0 {
+		if bounds[0] > bounds[1] {
+			log.Fatalf("lo must be at most hi, not %d > %d", bounds[0], bounds[1])
+		}
+		lo, err := prime.CountContext(ctx, bounds[0]-1)
+		if err != nil {
+			log.Fatal(err)
+		}
+		hi -= lo
+	}
+	fmt.Println(hi)
+}
+
+// parseCount parses a number like 1000000, 1e6 or 10^6 below 2^64.
+func parseCount(s string) (uint64, error) {
+	// s = m * b^e
+	m, b, e := s, "1", "0"
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		m, b, e = s[:i], "10", s[i+1:]
+	} else if i := strings.IndexByte(s, '^'); i >= 0 {
+		m, b, e = "1", s[:i], s[i+1:]
+	}
+	var n, x big.Int
+	if _, ok := n.SetString(m, 10); !ok {
+		return 0, fmt.Errorf("cannot parse %q as a number", s)
+	}
+	if _, ok := x.SetString(b, 10); !ok {
+		return 0, fmt.Errorf("cannot parse %q as a number", s)
+	}
+	k, err := strconv.ParseUint(e, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %q as a number", s)
+	}
+	n.Mul(&n, x.Exp(&x, new(big.Int).SetUint64(k), nil))
+	if !n.IsUint64() {
+		return 0, fmt.Errorf("%s must be between 0 and 2^64-1", s)
+	}
+	return n.Uint64(), nil
+}
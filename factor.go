@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/ascii85"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tscholl2/prime/prime"
+)
+
+func factorMain(args []string) {
+	fs := flag.NewFlagSet("factor", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println(`prime factor: factor N and print its prime factors to stdout
+Example: 'prime factor 1001' prints: 1001: 7 11 13
+Example: 'prime factor 506805269509150157511389557304054491891' prints: 506805269509150157511389557304054491891: 22101405602880366353 22930906686002846147
+Example: 'prime factor -ecm 340282366920938463463374607431768211457' prints: 340282366920938463463374607431768211457: 59649589127497217 5704689200685129054721
+Example: 'prime factor -json -f 16 3e9' prints: {"n":1001,"factors":[{"p":7,"e":1},{"p":11,"e":1},{"p":13,"e":1}]}
+Example: 'prime -f 0 -b 64 | prime factor -f 0' factors the raw bytes read from stdin
+N is read from stdin if it is '-' or -f is 0. The methods are chosen as
+in prime.FactorWithOptions: trial division, prime.Factor64 below 2^64,
+p-1 and p+1 with the bounds B1 and B2, Pollard rho, then ECM for factors
+of up to 4/13 of the digits and the quadratic sieve, or ECM for as long
+as it takes above 100 digits or with -ecm. Progress is printed to stderr.
+Options:`)
+		fs.PrintDefaults()
+	}
+	var B1, B2 uint64
+	var ecm, asJSON, quiet bool
+	var curves, j, f int
+	var timeout time.Duration
+	fs.BoolVar(&ecm, "ecm", false, "use only the elliptic curve method on what p-1, p+1 and rho cannot split")
+	fs.Uint64Var(&B1, "B1", 11000, "stage 1 bound for p-1, p+1 and -ecm, larger finds larger factors but takes longer")
+	fs.Uint64Var(&B2, "B2", 0, "stage 2 bound [default: 100 B1]")
+	fs.IntVar(&curves, "curves", 0, "with -ecm give up after this many curves, 0 for no limit")
+	fs.IntVar(&j, "j", 1, "number of workers sieving or running curves in parallel, 0 for one per CPU")
+	fs.IntVar(&f, "f", 10, "format of N, as output by 'prime -f' [supports: 0,2-36,64,85]")
+	fs.BoolVar(&asJSON, "json", false, "output JSON instead of text")
+	fs.BoolVar(&quiet, "q", false, "do not print progress to stderr")
+	fs.DurationVar(&timeout, "timeout", 0, "give up after this long, e.g. 30s [default: never]")
+	fs.Parse(args)
+	if fs.NArg() > 1 || (fs.NArg() == 0 && f != 0) {
+		fs.Usage()
+		os.Exit(2)
+	}
+	N, err := parseNumber(fs.Arg(0), f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if N.Sign() <= 0 {
+		log.Fatalf("N must be a positive integer, not %d", N)
+	}
+	ctx, cancel := timeoutContext(timeout)
+	defer cancel()
+	var progress func(string)
+	if !quiet {
+		progress = func(s string) { fmt.Fprintln(os.Stderr, "factor: "+s) }
+	}
+
+	F, err := prime.FactorWithOptions(ctx, N, &prime.FactorOptions{
+		B1:       B1,
+		B2:       B2,
+		ECM:      ecm,
+		Curves:   curves,
+		Workers:  j,
+		Progress: progress,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if asJSON {
+		b, err := json.Marshal(struct {
+			N       *big.Int            `json:"n"`
+			Factors prime.Factorization `json:"factors"`
+		}{N, F})
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(b))
+		return
+	}
+	s := []string{N.String() + ":"}
+	for _, pe := range F {
+		for i := uint64(0); i < pe.E; i++ {
+			s = append(s, pe.P.String())
+		}
+	}
+	fmt.Println(strings.Join(s, " "))
+}
+
+// parseNumber parses s in the format f as output by 'prime -f',
+// reading it from stdin if s is "-" or f is 0.
+func parseNumber(s string, f int) (*big.Int, error) {
+	if s == "-" || f == 0 {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		if f == 0 {
+			return new(big.Int).SetBytes(b), nil
+		}
+		s = string(b)
+	}
+	s = strings.TrimSpace(s)
+	var b []byte
+	var err error
+	switch {
+	case 2 <= f && f <= 36:
+		if N, ok := new(big.Int).SetString(s, f); ok {
+			return N, nil
+		}
+		return nil, fmt.Errorf("cannot parse %q as a number in base %d", s, f)
+	case f == 64:
+		b, err = base64.StdEncoding.DecodeString(s)
+	case f == 85:
+		b, err = io.ReadAll(ascii85.NewDecoder(strings.NewReader(s)))
+	default:
+		return nil, fmt.Errorf("unknown base %d", f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %q as a number in base %d: %v", s, f, err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -2,33 +2,91 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
 	"encoding/ascii85"
 	"encoding/base64"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math/big"
 	"os"
+	"time"
 
 	"github.com/tscholl2/prime/prime"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "rsa":
+			rsaMain(os.Args[2:])
+			return
+		case "dhparam", "dsaparam":
+			paramsMain(os.Args[1], os.Args[2:])
+			return
+		case "count":
+			countMain(os.Args[2:])
+			return
+		case "nth":
+			nthMain(os.Args[2:])
+			return
+		case "factor":
+			factorMain(os.Args[2:])
+			return
+		}
+	}
 	flag.CommandLine.Usage = func() {
 		fmt.Println(`prime: generate a prime number and print to stdout
 Example: 'prime -f 16 -b 128' prints: 83b19881300529d1fd4dac680415c60f
 Example: 'prime -f 64 -b 256' prints: zUwiK96O4sy6pm3LtQM5YtRP9L4RGxsU/zCNliZZXn0=
 Example: 'prime -f  0 -b 1024 > p.bytes' saves the raw bytes to the file 'p.bytes'
+Example: 'prime -seed test -b 64' always prints: 17441618005361717747
+Commands:
+  rsa	generate an RSA private key, see 'prime rsa -h'
+  dhparam	generate Diffie-Hellman parameters, see 'prime dhparam -h'
+  dsaparam	generate DSA parameters, see 'prime dsaparam -h'
+  count	count the primes up to x, see 'prime count -h'
+  nth	find the nth prime, see 'prime nth -h'
+  factor	factor a number, see 'prime factor -h'
 Options:`)
 		flag.CommandLine.PrintDefaults()
 	}
-	var b, f int
+	var b, f, j int
+	var safe bool
+	var seed string
+	var timeout time.Duration
 	flag.IntVar(&b, "b", 128, "number of bits [supports: 2,...,128,...]")
 	flag.IntVar(&f, "f", 10, "format of output [supports: 0,2-36,64,85]")
+	flag.BoolVar(&safe, "safe", false, "generate a safe prime p, so (p-1)/2 is also prime")
+	flag.StringVar(&seed, "seed", "", "seed for reproducible output, the same seed gives the same prime")
+	flag.IntVar(&j, "j", 1, "number of workers searching in parallel, 0 for one per CPU, only 1 with -seed")
+	flag.DurationVar(&timeout, "timeout", 0, "give up after this long, e.g. 30s [default: never]")
 	flag.Parse()
 	if b <= 1 {
 		log.Fatalf("bits must be positive integer > 1, not %d", b)
 	}
-	p := prime.RandPrime(b)
+	if seed != "" && j != 1 {
+		// the workers would read the seeded stream in whatever
+		// order they are scheduled, so the prime would change
+		log.Fatalf("-seed needs -j 1 to be reproducible, not -j %d", j)
+	}
+	ctx, cancel := timeoutContext(timeout)
+	defer cancel()
+	var p *big.Int
+	var err error
+	if safe {
+		if b <= 2 {
+			log.Fatalf("bits must be > 2 for a safe prime, not %d", b)
+		}
+		p, err = prime.SafePrimeParallel(ctx, randomSource(seed), b, j)
+	} else {
+		p, err = prime.PrimeParallel(ctx, randomSource(seed), b, j)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 	var s string
 	switch {
 	case f == 0:
@@ -49,3 +107,21 @@ Options:`)
 	}
 	fmt.Println(s)
 }
+
+// randomSource returns crypto/rand or, if seed is
+// set, a deterministic generator seeded with it.
+func randomSource(seed string) io.Reader {
+	if seed == "" {
+		return rand.Reader
+	}
+	return prime.NewDRBG([]byte(seed))
+}
+
+// timeoutContext returns a context which is done after
+// timeout, or never if timeout is not positive.
+func timeoutContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
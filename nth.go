@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/tscholl2/prime/prime"
+)
+
+func nthMain(args []string) {
+	fs := flag.NewFlagSet("nth", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println(`prime nth: find the nth prime and print to stdout
+Example: 'prime nth 1000' prints: 7919
+Example: 'prime nth 1e10' prints: 252097800623
+Numbers can be written as 1000000, 1e6 or 10^6
+Options:`)
+		fs.PrintDefaults()
+	}
+	var timeout time.Duration
+	fs.DurationVar(&timeout, "timeout", 0, "give up after this long, e.g. 30s [default: never]")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return
+	}
+	n, err := parseCount(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, cancel := timeoutContext(timeout)
+	defer cancel()
+	p, err := prime.NthContext(ctx, n)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(p)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+
+	"github.com/tscholl2/prime/prime"
+)
+
+// paramsMain runs the dhparam and dsaparam commands which
+// only differ in the PEM output.
+func paramsMain(name string, args []string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf(`prime %s: generate FIPS 186-4 domain parameters (p, q, g) and print to stdout
+Example: 'prime %[1]s -b 2048 -q 256' prints a 2048 bit p with a 256 bit q dividing p-1
+Example: 'prime %[1]s -json' prints p, q, g, the seed and counter as JSON
+Options:
+`, name)
+		fs.PrintDefaults()
+	}
+	var b, q int
+	var js, x942 bool
+	var seed string
+	fs.IntVar(&b, "b", 2048, "number of bits in p [supports: 1024,2048,3072,...]")
+	fs.IntVar(&q, "q", 256, "number of bits in q [supports: 160,224,256,...]")
+	fs.BoolVar(&js, "json", false, "output JSON instead of PEM")
+	if name == "dhparam" {
+		fs.BoolVar(&x942, "x942", false, "output X9.42 parameters with q, the seed and counter instead of PKCS #3")
+	}
+	fs.StringVar(&seed, "seed", "", "seed for reproducible output, the same seed gives the same parameters")
+	fs.Parse(args)
+	d, err := prime.GenerateDSAParams(randomSource(seed), b, q)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if js {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(d); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	var block *pem.Block
+	if name == "dhparam" && x942 {
+		// RFC 3279 DomainParameters, which unlike PKCS #3
+		// DHparams keep q and the ValidationParms so p and q
+		// can be checked to come from the seed
+		type validationParms struct {
+			Seed        asn1.BitString
+			PgenCounter int
+		}
+		der, err := asn1.Marshal(struct {
+			P, G, Q         *big.Int
+			ValidationParms validationParms
+		}{d.P, d.G, d.Q, validationParms{asn1.BitString{Bytes: d.Seed, BitLength: 8 * len(d.Seed)}, d.Counter}})
+		if err != nil {
+			log.Fatal(err)
+		}
+		block = &pem.Block{Type: "X9.42 DH PARAMETERS", Bytes: der}
+	} else if name == "dhparam" {
+		// PKCS #3 DHparams
+		der, err := asn1.Marshal(struct{ P, G *big.Int }{d.P, d.G})
+		if err != nil {
+			log.Fatal(err)
+		}
+		block = &pem.Block{Type: "DH PARAMETERS", Bytes: der}
+	} else {
+		// RFC 3279 Dss-Parms
+		der, err := asn1.Marshal(struct{ P, Q, G *big.Int }{d.P, d.Q, d.G})
+		if err != nil {
+			log.Fatal(err)
+		}
+		block = &pem.Block{Type: "DSA PARAMETERS", Bytes: der}
+	}
+	pem.Encode(os.Stdout, block)
+}
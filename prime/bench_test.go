@@ -1,7 +1,9 @@
 package prime
 
 import (
+	"context"
 	"crypto/rand"
+	"fmt"
 	"math/big"
 	random "math/rand"
 	"testing"
@@ -56,6 +58,85 @@ func BenchmarkTrailingZeroBits(b *testing.B) {
 	}
 }
 
+func BenchmarkFactor(b *testing.B) {
+	// two 40 bit primes
+	N := new(big.Int).Mul(big.NewInt(1<<40-87), big.NewInt(1<<40+15))
+	for i := 0; i < b.N; i++ {
+		Factor(N)
+	}
+}
+
+func BenchmarkFactor64(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Factor64(4294967291 * 4294967279)
+	}
+}
+
+func BenchmarkFactor64Random(b *testing.B) {
+	r := random.New(random.NewSource(1))
+	for i := 0; i < b.N; i++ {
+		Factor64(r.Uint64())
+	}
+}
+
+func BenchmarkPMinus1(b *testing.B) {
+	N := new(big.Int).Mul(benchmarkPrime, rough64)
+	for i := 0; i < b.N; i++ {
+		PMinus1(N, 1000, 100000)
+	}
+}
+
+func BenchmarkPPlus1(b *testing.B) {
+	N := new(big.Int).Mul(benchmarkPrime, rough64)
+	for i := 0; i < b.N; i++ {
+		PPlus1(N, 1000, 100000)
+	}
+}
+
+func BenchmarkECM(b *testing.B) {
+	// one curve
+	N := new(big.Int).Mul(benchmarkPrime, rough64)
+	for i := 0; i < b.N; i++ {
+		ECM(N, 2000, 200000, 1)
+	}
+}
+
+func BenchmarkCFRAC(b *testing.B) {
+	N := new(big.Int).Mul(big.NewInt(4294967291), rough62)
+	for i := 0; i < b.N; i++ {
+		CFRAC(N)
+	}
+}
+
+func BenchmarkSIQS(b *testing.B) {
+	N := new(big.Int).Mul(rough62, rough64)
+	for i := 0; i < b.N; i++ {
+		SIQS(N)
+	}
+}
+
+// primality proofs
+
+func BenchmarkECPPCertificate(b *testing.B) {
+	for _, bits := range []int{512, 1024, 2048} {
+		b.Run(fmt.Sprintf("%d", bits), func(b *testing.B) {
+			p := RandPrime(bits)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ECPPCertificate(p)
+			}
+		})
+	}
+}
+
+func BenchmarkVerifyECPP(b *testing.B) {
+	cert := ECPPCertificate(RandPrime(512))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		VerifyECPP(cert)
+	}
+}
+
 // utility primality tests
 
 func BenchmarkSmallPrimeTest(b *testing.B) {
@@ -106,12 +187,36 @@ func BenchmarkNextPrime(b *testing.B) {
 	}
 }
 
+func BenchmarkNextPrimeParallel(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		NextPrimeParallel(context.Background(), randBig(1024), 0)
+	}
+}
+
 func BenchmarkRandPrime(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		RandPrime(1024)
 	}
 }
 
+func BenchmarkPrimeParallel(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		PrimeParallel(context.Background(), rand.Reader, 1024, 0)
+	}
+}
+
+func BenchmarkRandSafePrime(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		RandSafePrime(512)
+	}
+}
+
+func BenchmarkRandStrongPrime(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		RandStrongPrime(1024)
+	}
+}
+
 func BenchmarkCryptoRandPrime(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		rand.Prime(rand.Reader, 1024)
@@ -160,3 +265,23 @@ func BenchmarkSolovayStrassenRandom(b *testing.B) {
 		SolovayStrassen(randBig(1024), 20)
 	}
 }
+
+// sieve
+
+func BenchmarkSieve(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Sieve(1000000000000, 1000000000000+10000000, func(uint64) bool { return true })
+	}
+}
+
+func BenchmarkCount(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Count(1000000000000)
+	}
+}
+
+func BenchmarkNth(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Nth(10000000000)
+	}
+}
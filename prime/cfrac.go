@@ -0,0 +1,301 @@
+package prime
+
+import (
+	"context"
+	"iter"
+	"math/big"
+	"math/bits"
+)
+
+// cfracParams is the factor base size for N of about bits
+// bits, between the rows the values are interpolated.
+var cfracParams = []struct {
+	bits, fb int
+}{
+	{64, 60},
+	{96, 150},
+	{128, 400},
+	{160, 1000},
+	{192, 2500},
+	{224, 5000},
+}
+
+const (
+	// Q is given up on unless after trial division by the
+	// first 1/cfracAbort of the factor base its size is at
+	// most 1/cfracAbortBits of the way to a large prime
+	cfracAbort     = 4
+	cfracAbortBits = 2
+	// rounds of siqsExtra more relations to try when every
+	// square is trivial before the next multiplier
+	cfracRetries = 4
+)
+
+// SqrtContinuedFraction returns the partial quotients a0, a1, ...
+// of the continued fraction of sqrt(N). If N is not a square the
+// sequence does not end: after a0 it is periodic, with the period
+// ending in 2a0. If N is a square it is just sqrt(N), and if N is
+// negative it is empty.
+//
+// See Niven, Zuckerman and Montgomery, "An Introduction to the
+// Theory of Numbers", Section 7.8.
+func SqrtContinuedFraction(N *big.Int) iter.Seq[*big.Int] {
+	return func(yield func(*big.Int) bool) {
+		if N.Sign() < 0 {
+			return
+		}
+		cf := newSqrtCF(N)
+		if !yield(new(big.Int).Set(cf.a)) || IsSquare(N) {
+			return
+		}
+		for {
+			cf.next()
+			if !yield(new(big.Int).Set(cf.a)) {
+				return
+			}
+		}
+	}
+}
+
+// Pell returns the least solution in positive integers of
+// x^2 - D y^2 = 1, or nil, nil if D is not positive or is
+// a square, when there is none. The solution comes from
+// the convergents at the end of the first period of the
+// continued fraction of sqrt(D), or of the second if the
+// period has odd length. It can have as many digits as
+// sqrt(D), so D should not be too large.
+//
+// See Lenstra, "Solving the Pell equation" (2002).
+func Pell(D *big.Int) (x, y *big.Int) {
+	if D.Sign() <= 0 || IsSquare(D) {
+		return nil, nil
+	}
+	// x/y runs through the convergents with
+	// x^2 - D y^2 = (-1)^(k+1) Q_(k+1)
+	cf := newSqrtCF(D)
+	x0, y0 := big.NewInt(1), big.NewInt(0)
+	x, y = new(big.Int).Set(cf.a), big.NewInt(1)
+	z := new(big.Int)
+	for k := 0; ; k++ {
+		cf.next()
+		if k%2 == 1 && cf.Q.Cmp(one) == 0 {
+			return x, y
+		}
+		x0, x = x, x0.Add(x0, z.Mul(cf.a, x))
+		y0, y = y, y0.Add(y0, z.Mul(cf.a, y))
+	}
+}
+
+// sqrtCF is the state of the continued fraction of sqrt(N), the
+// kth complete quotient being (P_k + sqrt(N))/Q_k and the kth
+// partial quotient its floor a_k.
+type sqrtCF struct {
+	a0, P, Q, Q0, a *big.Int
+	z               *big.Int
+}
+
+func newSqrtCF(N *big.Int) *sqrtCF {
+	a0 := new(big.Int).Sqrt(N)
+	// Q_-1 = N so that Q_1 = N - a0^2 below
+	return &sqrtCF{
+		a0: a0,
+		P:  new(big.Int),
+		Q:  big.NewInt(1),
+		Q0: new(big.Int).Set(N),
+		a:  new(big.Int).Set(a0),
+		z:  new(big.Int),
+	}
+}
+
+// next moves from k to k + 1 with
+// P_(k+1) = a_k Q_k - P_k,
+// Q_(k+1) = Q_(k-1) + a_k (P_k - P_(k+1)),
+// a_(k+1) = floor((a0 + P_(k+1)) / Q_(k+1)).
+func (cf *sqrtCF) next() {
+	z := cf.z
+	z.Mul(cf.a, cf.Q)
+	z.Sub(z, cf.P) // P_(k+1)
+	cf.P.Sub(cf.P, z)
+	cf.P.Mul(cf.P, cf.a)
+	cf.Q0.Add(cf.Q0, cf.P) // Q_(k+1)
+	cf.P.Set(z)
+	cf.Q, cf.Q0 = cf.Q0, cf.Q
+	z.Add(cf.a0, cf.P)
+	cf.a.Quo(z, cf.Q)
+}
+
+// CFRAC looks for a factor of N with the continued fraction
+// method of Morrison and Brillhart. The convergents A/B of
+// sqrt(kN) have A^2 = +-Q mod N with Q < 2 sqrt(kN), and the
+// Q which factor over the small primes, allowing one larger
+// prime, are combined into X^2 = Y^2 mod N. If every square
+// is trivial, or the period of the continued fraction ends
+// first, it starts again with the next best multiplier k. It
+// is slower than SIQS but shares none of its sieving, so each
+// can check the other. It returns nil if N is prime and a
+// root of N if it is a perfect power.
+//
+// See Morrison and Brillhart, "A method of factoring and the
+// factorization of F7" (1975).
+func CFRAC(N *big.Int) *big.Int {
+	d, _ := CFRACContext(context.Background(), N)
+	return d
+}
+
+// CFRACContext is CFRAC but checks ctx every so often
+// and stops with ctx.Err() once it is done.
+func CFRACContext(ctx context.Context, N *big.Int) (*big.Int, error) {
+	// Step 0: parse input / easy cases
+	if N.Cmp(big.NewInt(4)) < 0 || BPSW(N) != IsComposite {
+		return nil, nil
+	}
+	if N.Bit(0) == 0 {
+		return big.NewInt(2), nil
+	}
+	// every square mod a prime power is trivial,
+	// and N is odd so any root of it is at least 3
+	if a, _, err := factorPower(ctx, N, 1); a != nil || err != nil {
+		return a, err
+	}
+
+	// Step 1: the size of the factor base
+	fbSize := cfracParams[0].fb
+	for i, p := range cfracParams {
+		if N.BitLen() <= p.bits {
+			if i > 0 {
+				q := cfracParams[i-1]
+				t := float64(N.BitLen()-q.bits) / float64(p.bits-q.bits)
+				fbSize = q.fb + int(t*float64(p.fb-q.fb))
+			}
+			break
+		}
+		fbSize = p.fb
+	}
+
+	// Step 2: try each multiplier, best first
+	for _, k := range siqsMultipliers(N) {
+		if d, err := cfrac(ctx, N, k, fbSize); d != nil || err != nil {
+			return d, err
+		}
+	}
+	return nil, nil
+}
+
+// cfrac is CFRACContext with the multiplier k. It returns nil,
+// nil if the period ends or after cfracRetries rounds of
+// trivial squares.
+func cfrac(ctx context.Context, N *big.Int, k uint64, fbSize int) (*big.Int, error) {
+	// Step 1: the factor base of 2 and the
+	// odd primes p with kN a square mod p
+	K := new(big.Int).SetUint64(k)
+	if d := nontrivial(new(big.Int).GCD(nil, nil, K, N), N); d != nil {
+		return d, nil
+	}
+	kN := new(big.Int).Mul(N, K)
+	fb := []uint32{1, 2}
+	z, P := new(big.Int), new(big.Int)
+	for p := uint64(3); len(fb) < fbSize; p += 2 {
+		if !isPrime64(p) {
+			continue
+		}
+		r := z.Mod(kN, P.SetUint64(p)).Uint64()
+		if r == 0 && z.Mod(N, P).Sign() == 0 {
+			return P, nil
+		}
+		if r == 0 || powMod64(r, (p-1)/2, p) == 1 {
+			fb = append(fb, uint32(p))
+		}
+	}
+	pmax := uint64(fb[len(fb)-1])
+	large := min(siqsLargeMultiplier*pmax, pmax*pmax)
+	abort := len(fb) / cfracAbort
+	abortBits := bits.Len64(large) + (kN.BitLen()/2-bits.Len64(large))/cfracAbortBits
+
+	// Step 2: walk the continued fraction of sqrt(kN) until
+	// there are more relations than primes, then look for
+	// a square
+	cf := newSqrtCF(kN)
+	twoA0 := new(big.Int).Lsh(cf.a0, 1)
+	A0, A := big.NewInt(1), new(big.Int).Mod(cf.a0, N)
+	rs := newRelations(N)
+	need := len(fb) + siqsExtra
+	Q := new(big.Int)
+	for i, retries := 0, 0; ; i++ {
+		if i%1024 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// the end of the period, after which
+		// the relations repeat
+		if cf.a.Cmp(twoA0) == 0 {
+			return nil, nil
+		}
+		cf.next()
+
+		// A_i^2 = (-1)^(i+1) Q_(i+1) mod N
+		var factors []uint32
+		if i%2 == 0 {
+			factors = append(factors, 0)
+		}
+		Q.Set(cf.Q)
+		for j := Q.TrailingZeroBits(); j > 0; j-- {
+			factors = append(factors, 1)
+		}
+		Q.Rsh(Q, Q.TrailingZeroBits())
+		j := 2
+		for ; j < len(fb) && !Q.IsUint64(); j++ {
+			p := uint64(fb[j])
+			for modWords(Q.Bits(), p) == 0 {
+				Q.Quo(Q, P.SetUint64(p))
+				factors = append(factors, uint32(j))
+			}
+		}
+		// the rest is done with uint64s, giving up early on a Q
+		// which is still too large after the first few primes,
+		// or at once on one which never got below 2^64
+		var q uint64
+		if Q.IsUint64() {
+			q = Q.Uint64()
+		}
+		for ; j < len(fb) && q > 1; j++ {
+			p := uint64(fb[j])
+			for q%p == 0 {
+				q /= p
+				factors = append(factors, uint32(j))
+			}
+			if j == abort && bits.Len64(q) > abortBits {
+				q = 0
+			}
+		}
+		if q != 0 && q <= large {
+			rs.add(relation{X: new(big.Int).Set(A), factors: factors}, q)
+		}
+		A0, A = A, A0.Mod(A0.Add(A0, z.Mul(cf.a, A)), N)
+
+		if len(rs.full) < need {
+			continue
+		}
+		if d := combineSquares(N, fb, rs.full); d != nil {
+			return d, nil
+		}
+		// every square was trivial, so find some more,
+		// unless that keeps happening with this k
+		if retries++; retries > cfracRetries {
+			return nil, nil
+		}
+		need += siqsExtra
+	}
+}
+
+// modWords returns x mod p for x given by its words,
+// least significant first, and p < 2^32.
+func modWords(x []big.Word, p uint64) uint64 {
+	r := uint64(0)
+	for i := len(x) - 1; i >= 0; i-- {
+		if bits.UintSize == 64 {
+			r = bits.Rem64(r, uint64(x[i]), p)
+		} else {
+			r = (r<<32 | uint64(x[i])) % p
+		}
+	}
+	return r
+}
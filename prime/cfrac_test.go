@@ -0,0 +1,98 @@
+package prime
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSqrtContinuedFraction(t *testing.T) {
+	cases := []struct {
+		N int64
+		a []int64
+	}{
+		{0, []int64{0}},
+		{1, []int64{1}},
+		{2, []int64{1, 2, 2, 2, 2}},
+		{14, []int64{3, 1, 2, 1, 6, 1, 2, 1, 6}},
+		{25, []int64{5}},
+		{61, []int64{7, 1, 4, 3, 1, 2, 2, 1, 3, 4, 1, 14, 1}},
+		{-1, nil},
+	}
+	for _, c := range cases {
+		var a []int64
+		for q := range SqrtContinuedFraction(big.NewInt(c.N)) {
+			if a = append(a, q.Int64()); len(a) == len(c.a) {
+				break
+			}
+		}
+		assert.Equal(t, c.a, a, fmt.Sprintf("N=%d", c.N))
+	}
+}
+
+func TestPell(t *testing.T) {
+	cases := []struct {
+		D    int64
+		x, y string
+	}{
+		{2, "3", "2"},
+		{3, "2", "1"},
+		{13, "649", "180"},
+		{61, "1766319049", "226153980"},
+		{109, "158070671986249", "15140424455100"},
+		{991, "379516400906811930638014896080", "12055735790331359447442538767"},
+	}
+	for _, c := range cases {
+		x, y := Pell(big.NewInt(c.D))
+		require.NotNil(t, x, fmt.Sprintf("D=%d", c.D))
+		assert.Equal(t, c.x, x.String(), fmt.Sprintf("D=%d", c.D))
+		assert.Equal(t, c.y, y.String(), fmt.Sprintf("D=%d", c.D))
+	}
+	for _, D := range []int64{-2, 0, 1, 49} {
+		x, y := Pell(big.NewInt(D))
+		assert.Nil(t, x, fmt.Sprintf("D=%d", D))
+		assert.Nil(t, y, fmt.Sprintf("D=%d", D))
+	}
+	// x^2 - D y^2 = 1 for a large D
+	D := new(big.Int).SetUint64(1<<40 + 1)
+	x, y := Pell(D)
+	z := new(big.Int).Mul(x, x)
+	z.Sub(z, new(big.Int).Mul(D, new(big.Int).Mul(y, y)))
+	assert.Equal(t, one, z)
+}
+
+func TestCFRAC(t *testing.T) {
+	cases := []struct {
+		p, q *big.Int
+	}{
+		{big.NewInt(65537), big.NewInt(65539)},
+		{big.NewInt(2147483647), big.NewInt(4294967291)},
+		{big.NewInt(1<<40 - 87), big.NewInt(1<<40 + 15)},
+		{big.NewInt(4294967291), rough62},
+		// every square is trivial with the first multiplier k = 3
+		{big.NewInt(1000037), big.NewInt(1000039)},
+	}
+	for _, c := range cases {
+		N := new(big.Int).Mul(c.p, c.q)
+		d := CFRAC(N)
+		require.NotNil(t, d, fmt.Sprintf("N=%d", N))
+		assert.True(t, d.Cmp(c.p) == 0 || d.Cmp(c.q) == 0, fmt.Sprintf("N=%d, d=%d", N, d))
+		// the same factors as SIQS
+		e := SIQS(N)
+		assert.True(t, e.Cmp(c.p) == 0 || e.Cmp(c.q) == 0, fmt.Sprintf("N=%d, e=%d", N, e))
+	}
+	assert.Nil(t, CFRAC(rough64))
+	assert.Equal(t, big.NewInt(2), CFRAC(big.NewInt(1<<40)))
+	assert.Equal(t, rough62, CFRAC(new(big.Int).Mul(rough62, rough62)))
+	// a cube of a prime above 2^64
+	p := NextPrime(new(big.Int).Lsh(one, 65))
+	assert.Equal(t, p, CFRAC(new(big.Int).Exp(p, big.NewInt(3), nil)))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := CFRACContext(ctx, new(big.Int).Mul(rough62, rough64))
+	assert.Equal(t, context.Canceled, err)
+}
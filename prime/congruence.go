@@ -0,0 +1,207 @@
+package prime
+
+import (
+	"math/big"
+)
+
+// relation is a congruence X^2 = Y^2 * L^2 mod N where Y^2 is
+// the product of fb[i] for i in factors, with repeats, and a
+// factor base fb with fb[0] standing for -1. Partial relations
+// have one large prime outside the factor base and are paired
+// up by relations.add, which keeps their product as L.
+type relation struct {
+	X       *big.Int
+	factors []uint32
+	L       *big.Int
+}
+
+// relations collects full relations and pairs up partial ones.
+type relations struct {
+	N       *big.Int
+	full    []relation
+	partial map[uint64]relation
+	seen    map[string]bool
+}
+
+func newRelations(N *big.Int) *relations {
+	return &relations{
+		N:       N,
+		partial: make(map[uint64]relation),
+		seen:    make(map[string]bool),
+	}
+}
+
+// add adds r, which also has the prime large as a factor
+// unless large <= 1. A partial relation is kept until
+// another with the same large prime turns up, then the two
+// are multiplied together into a full one. Duplicates are
+// ignored.
+func (rs *relations) add(r relation, large uint64) {
+	key := r.X.String()
+	if rs.seen[key] {
+		return
+	}
+	rs.seen[key] = true
+	if large <= 1 {
+		rs.full = append(rs.full, r)
+		return
+	}
+	s, ok := rs.partial[large]
+	if !ok {
+		rs.partial[large] = r
+		return
+	}
+	X := new(big.Int).Mul(r.X, s.X)
+	factors := make([]uint32, 0, len(r.factors)+len(s.factors))
+	factors = append(append(factors, r.factors...), s.factors...)
+	rs.full = append(rs.full, relation{
+		X:       X.Mod(X, rs.N),
+		factors: factors,
+		L:       new(big.Int).SetUint64(large),
+	})
+}
+
+// combineSquares looks for a subset of rels with X^2 = Y^2 mod N
+// by Gaussian elimination over GF(2) on the exponents mod 2, and
+// returns gcd(X - Y, N) for the first subset with 1 < gcd < N,
+// or nil if every subset gives a trivial one.
+//
+// See Crandall and Pomerance, "Prime Numbers", Section 6.1.
+func combineSquares(N *big.Int, fb []uint32, rels []relation) *big.Int {
+	// Step 1: the factors with an odd exponent in each relation
+	odd := make([][]uint32, len(rels))
+	parity := make([]bool, len(fb))
+	for i, r := range rels {
+		for _, j := range r.factors {
+			parity[j] = !parity[j]
+		}
+		for _, j := range r.factors {
+			if parity[j] {
+				odd[i] = append(odd[i], j)
+				parity[j] = false
+			}
+		}
+	}
+
+	// Step 2: a relation with the only odd exponent of some
+	// factor cannot be in any subset, so drop it and repeat
+	weight := make([]int, len(fb))
+	for _, o := range odd {
+		for _, j := range o {
+			weight[j]++
+		}
+	}
+	keep := make([]bool, len(rels))
+	for i := range keep {
+		keep[i] = true
+	}
+	for changed := true; changed; {
+		changed = false
+		for i, o := range odd {
+			if !keep[i] {
+				continue
+			}
+			for _, j := range o {
+				if weight[j] == 1 {
+					keep[i], changed = false, true
+					for _, j := range o {
+						weight[j]--
+					}
+					break
+				}
+			}
+		}
+	}
+	column := make([]int, len(fb))
+	ncol := 0
+	for j, w := range weight {
+		column[j] = -1
+		if w > 0 {
+			column[j] = ncol
+			ncol++
+		}
+	}
+	var rows []int
+	for i := range rels {
+		// more rows than columns + 64 only adds work
+		if keep[i] && len(rows) < ncol+64 {
+			rows = append(rows, i)
+		}
+	}
+
+	// Step 3: each row is its odd columns followed by the
+	// set of rows it is the sum of, and every row which is
+	// not a pivot ends up with no odd columns left
+	cw := (ncol + 63) / 64
+	hw := (len(rows) + 63) / 64
+	M := make([][]uint64, len(rows))
+	for r, i := range rows {
+		M[r] = make([]uint64, cw+hw)
+		for _, j := range odd[i] {
+			c := column[j]
+			M[r][c/64] |= 1 << uint(c%64)
+		}
+		M[r][cw+r/64] |= 1 << uint(r%64)
+	}
+	pivot := make([]bool, len(rows))
+	for c := 0; c < ncol; c++ {
+		w, b := c/64, uint(c%64)
+		p := -1
+		for r := range M {
+			if !pivot[r] && M[r][w]>>b&1 == 1 {
+				p = r
+				break
+			}
+		}
+		if p < 0 {
+			continue
+		}
+		pivot[p] = true
+		// columns before c are already clear in row p
+		P := M[p][w:]
+		for r := range M {
+			if r != p && M[r][w]>>b&1 == 1 {
+				row := M[r][w:]
+				for k := range P {
+					row[k] ^= P[k]
+				}
+			}
+		}
+	}
+
+	// Step 4: for each dependency X is the product of the
+	// X's and Y the square root of the product of the rest
+	exps := make([]uint32, len(fb))
+	X, Y, z := new(big.Int), new(big.Int), new(big.Int)
+	for r := range M {
+		if pivot[r] {
+			continue
+		}
+		clear(exps)
+		X.SetInt64(1)
+		Y.SetInt64(1)
+		for s := range rows {
+			if M[r][cw+s/64]>>uint(s%64)&1 == 0 {
+				continue
+			}
+			rel := rels[rows[s]]
+			X.Mod(X.Mul(X, rel.X), N)
+			if rel.L != nil {
+				Y.Mod(Y.Mul(Y, rel.L), N)
+			}
+			for _, j := range rel.factors {
+				exps[j]++
+			}
+		}
+		for j := 1; j < len(fb); j++ {
+			if exps[j] > 0 {
+				z.Exp(z.SetUint64(uint64(fb[j])), big.NewInt(int64(exps[j]/2)), N)
+				Y.Mod(Y.Mul(Y, z), N)
+			}
+		}
+		if g := nontrivial(z.GCD(nil, nil, z.Sub(X, Y), N), N); g != nil {
+			return new(big.Int).Set(g)
+		}
+	}
+	return nil
+}
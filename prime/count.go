@@ -0,0 +1,495 @@
+package prime
+
+import (
+	"context"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	// below this Count just sieves
+	countSieveLimit = 1 << 20
+	// phi(n, 6) is found from a table of its
+	// values mod 30030 = 2*3*5*7*11*13
+	countPhiPrimes = 6
+	countPhiPeriod = 30030
+	// the number of odd numbers sieved at a time when
+	// counting the hard leaves, and the number of words
+	// in a segment with one count of the bits set
+	countSegment = 1 << 16
+	countBlock   = 16
+	// pi(n) for n <= sqrt(x) adds up at most this many
+	// words of the bitmap of odd primes
+	countPiWords = 8
+)
+
+// Count returns pi(x), the number of primes p <= x.
+// It uses the Meissel-Lehmer method as improved by
+// Lagarias, Miller and Odlyzko, so it takes about x^(2/3)
+// steps and memory proportional to sqrt(x): a bitmap of the
+// primes up to sqrt(x) and tables for the numbers up to about
+// x^(1/3) log(x)^2 / 80, together about 650 MB for x near 2^64.
+func Count(x uint64) uint64 {
+	n, _ := CountContext(context.Background(), x)
+	return n
+}
+
+// CountContext is Count but gives up with ctx.Err()
+// once ctx is done.
+func CountContext(ctx context.Context, x uint64) (uint64, error) {
+	// Step 0: parse input / easy cases
+	if x < countSieveLimit {
+		var n uint64
+		Sieve(0, x+1, func(uint64) bool {
+			n++
+			return true
+		})
+		return n, nil
+	}
+
+	// Step 1: with a = pi(y) for some x^(1/3) <= y <= x^(1/2)
+	//
+	//	pi(x) = phi(x, a) + a - 1 - P2(x, a)
+	//
+	// where phi(x, a) counts the n <= x with none of the first
+	// a primes as factors, and P2(x, a) counts the n <= x which
+	// are a product of two primes p <= q with y < p.
+	c := newCounter(x)
+	a := c.pi(c.y)
+	s2, p2, err := c.sieve(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// The sums wrap around, but pi(x) < 2^64 so the total is right.
+	return c.ordinary() + c.easy() + s2 + a - 1 - p2, nil
+}
+
+// counter holds the tables for CountContext.
+type counter struct {
+	x, y, sqrt uint64
+	// the primes <= y, and odd[i] has bit j set if
+	// 128i + 2j + 1 <= sqrt(x) is prime while before[i]
+	// counts 2 and the odd primes below 128 countPiWords i
+	primes []uint32
+	odd    []uint64
+	before []uint32
+	// the least prime factor and the Mobius function of n <= y
+	lpf []uint32
+	mu  []int8
+	// phi(r, 6) for r < 30030
+	phi []uint32
+}
+
+func newCounter(x uint64) *counter {
+	sq := sqrt64(x)
+	// y = alpha x^(1/3) balances the sieve up to x/y
+	// against the number of leaves below y
+	alpha := max(1, math.Pow(math.Log(float64(x)), 2)/80)
+	y := min(uint64(alpha*float64(cbrt64(x))), sq)
+	c := &counter{x: x, y: y, sqrt: sq}
+
+	// Step 1: pi(n) for n <= sqrt(x), and the primes up to y,
+	// which include those up to sqrt(x/y) sieving for P2
+	c.odd = make([]uint64, sq/128+1)
+	top := max(y, sqrt64(x/y))
+	Sieve(0, sq+1, func(p uint64) bool {
+		if p <= top {
+			c.primes = append(c.primes, uint32(p))
+		}
+		if p > 2 {
+			c.odd[p/128] |= 1 << (p / 2 % 64)
+		}
+		return true
+	})
+	c.before = make([]uint32, (len(c.odd)+countPiWords-1)/countPiWords)
+	n := uint32(1)
+	for i, w := range c.odd {
+		if i%countPiWords == 0 {
+			c.before[i/countPiWords] = n
+		}
+		n += uint32(bits.OnesCount64(w))
+	}
+
+	// Step 2: lpf(n) and mu(n) for n <= y
+	c.lpf = make([]uint32, y+1)
+	c.mu = make([]int8, y+1)
+	for i := range c.mu {
+		c.mu[i] = 1
+	}
+	c.lpf[1] = math.MaxUint32
+	for _, p := range c.primes {
+		p := uint64(p)
+		if p > y {
+			break
+		}
+		for m := p; m <= y; m += p {
+			if c.lpf[m] == 0 {
+				c.lpf[m] = uint32(p)
+			}
+			c.mu[m] = -c.mu[m]
+		}
+		for m := p * p; m <= y; m += p * p {
+			c.mu[m] = 0
+		}
+	}
+
+	// Step 3: phi(r, 6) for r < 30030
+	c.phi = make([]uint32, countPhiPeriod)
+	for r := 1; r < countPhiPeriod; r++ {
+		c.phi[r] = c.phi[r-1]
+		if r%2 != 0 && r%3 != 0 && r%5 != 0 && r%7 != 0 && r%11 != 0 && r%13 != 0 {
+			c.phi[r]++
+		}
+	}
+	return c
+}
+
+// pi returns pi(n) for n <= sqrt(x).
+func (c *counter) pi(n uint64) uint64 {
+	if n < 2 {
+		return 0
+	}
+	// 2k + 1 is the largest odd number <= n
+	k := (n - 1) / 2
+	w := k / 64
+	s := uint64(c.before[w/countPiWords])
+	for _, v := range c.odd[w-w%countPiWords : w] {
+		s += uint64(bits.OnesCount64(v))
+	}
+	return s + uint64(bits.OnesCount64(c.odd[w]<<(63-k%64)))
+}
+
+// below returns the largest prime < n for 3 < n <= sqrt(x) + 1.
+func (c *counter) below(n uint64) uint64 {
+	// 2k + 1 is the largest odd number < n
+	k := (n - 2) / 2
+	for {
+		if w := c.odd[k/64] << (63 - k%64); w != 0 {
+			return 2*(k-uint64(bits.LeadingZeros64(w))) + 1
+		}
+		k -= k%64 + 1
+	}
+}
+
+// phi6 returns phi(n, 6).
+func (c *counter) phi6(n uint64) uint64 {
+	return n/countPhiPeriod*uint64(c.phi[countPhiPeriod-1]) + uint64(c.phi[n%countPhiPeriod])
+}
+
+// ordinary returns the sum over the ordinary leaves
+//
+//	S1 = sum_{n <= y, lpf(n) > 13} mu(n) phi(x/n, 6).
+//
+// Together with the special leaves
+//
+//	S2 = -sum_{6 <= b < a} sum_{y/p_{b+1} < m <= y, lpf(m) > p_{b+1}} mu(m) phi(x/(p_{b+1} m), b)
+//
+// it gives phi(x, a) = S1 + S2.
+func (c *counter) ordinary() (s1 uint64) {
+	for n := uint64(1); n <= c.y; n++ {
+		if c.mu[n] == 0 || c.lpf[n] <= c.primes[countPhiPrimes-1] {
+			continue
+		}
+		if v := c.phi6(c.x / n); c.mu[n] > 0 {
+			s1 += v
+		} else {
+			s1 -= v
+		}
+	}
+	return
+}
+
+// easy returns the sum over the special leaves with
+// n = x/(p_{b+1} m) < p_{b+1}^2, where
+//
+//	phi(n, b) = 1 + max(0, pi(n) - b).
+//
+// For p_{b+1} > x^(1/4) that is all of them, and n <= sqrt(x).
+func (c *counter) easy() (s2 uint64) {
+	x, y := c.x, c.y
+	a := c.pi(y)
+	for b := uint64(countPhiPrimes); b < a; b++ {
+		p := uint64(c.primes[b])
+		lo := max(y/p, x/p/p/p) // m > lo
+		if lo >= y {
+			continue
+		}
+		leaf := func(n uint64) uint64 {
+			if l := c.pi(n); l > b {
+				return 1 + l - b
+			}
+			return 1
+		}
+		// Step 1: m is a squarefree composite, so m > p^2
+		for m := max(lo, p*p) + 1; m <= y; m++ {
+			if c.mu[m] == 0 || uint64(c.lpf[m]) <= p || uint64(c.lpf[m]) == m {
+				continue
+			}
+			if v := leaf(x / (p * m)); c.mu[m] > 0 {
+				s2 -= v
+			} else {
+				s2 += v
+			}
+		}
+		// Step 2: m = q is prime and n > y, each n is different
+		j := c.pi(max(lo, p))
+		for ; j < a; j++ {
+			n := x / (p * uint64(c.primes[j]))
+			if n <= y {
+				break
+			}
+			s2 += 1 + c.pi(n) - b
+		}
+		// Step 3: m = q is prime and p <= n <= y, then q' >= q
+		// has the same l = pi(x/(p q')) as long as p q' p_l <= x,
+		// and the next l is usually l - 1
+		end := a
+		if w := x / p / p; w < y {
+			end = c.pi(w)
+		}
+		for l := uint64(0); j < end; {
+			if l == 0 {
+				l = c.pi(x / (p * uint64(c.primes[j])))
+			}
+			k := end
+			if w := x / (p * uint64(c.primes[l-1])); w < y {
+				k = min(end, c.pi(w))
+			}
+			if k == j {
+				l = 0
+				continue
+			}
+			s2 += (k - j) * (1 + l - b)
+			j, l = k, l-1
+		}
+		// Step 4: m = q is prime and n < p
+		s2 += a - max(j, end)
+	}
+	return
+}
+
+// sieve returns the sum over the hard special leaves, those
+// with p_{b+1} <= x^(1/4) and n >= p_{b+1}^2, and P2(x, a).
+// Both need counts of unsieved numbers up to x/y, which it
+// finds by sieving one segment at a time.
+func (c *counter) sieve(ctx context.Context) (s2, p2 uint64, err error) {
+	x, y, sq := c.x, c.y, c.sqrt
+	a := c.pi(y)
+	hard := max(min(a, c.pi(sqrt64(sq))), countPhiPrimes)
+	end := x/y + 1
+
+	// Step 1: the squarefree m <= y with lpf(m) > 13 going down,
+	// the leaves for b are found from ms[next[b]] on, and
+	// phi[b] = phi(low - 1, b)
+	var ms []uint32
+	for m := y; m > 0; m-- {
+		if c.mu[m] != 0 && c.lpf[m] > c.primes[countPhiPrimes-1] {
+			ms = append(ms, uint32(m))
+		}
+	}
+	next := make([]int, hard)
+	phi := make([]uint64, hard)
+	for b := countPhiPrimes; b < int(hard); b++ {
+		p := uint64(c.primes[b])
+		top := uint32(min(y, x/p/p/p))
+		next[b] = sort.Search(len(ms), func(i int) bool { return ms[i] <= top })
+	}
+	// P2 needs pi(x/q) for the primes q = p_{i+1} with a <= i <
+	// pi(sqrt(x)) going down, and pi = pi(u) for the last
+	// u >= sqrt(x) counted up to
+	u, pi := sq, c.pi(sq)
+	i, q := int(pi)-1, c.below(sq+1)
+
+	// Step 2: the odd numbers 2k + 1 without a factor in 3, ..., 13
+	// repeat with period 15015 in k, so segments start as a copy
+	const period = countPhiPeriod / 2
+	pattern := make([]uint64, (period+countSegment)/64+2)
+	for k := range 64 * len(pattern) {
+		if m := 2*k + 1; m%3 != 0 && m%5 != 0 && m%7 != 0 && m%11 != 0 && m%13 != 0 {
+			pattern[k/64] |= 1 << (k % 64)
+		}
+	}
+
+	// Step 3: sieve the odd numbers low, low+2, ..., high-1
+	words := make([]uint64, countSegment/64)
+	counts := make([]uint32, len(words)/countBlock)
+	for low := uint64(1); low < end; low += 2 * countSegment {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, err
+		}
+		high := min(low+2*countSegment, end)
+		n := (high - low + 1) / 2
+		seg := &segment{low: low, words: words[:(n+63)/64], counts: counts}
+		r := low / 2 % period
+		for j := range seg.words {
+			w, s := r/64+uint64(j), r%64
+			seg.words[j] = pattern[w] >> s
+			if s != 0 {
+				seg.words[j] |= pattern[w+1] << (64 - s)
+			}
+		}
+		if n%64 != 0 {
+			seg.words[len(seg.words)-1] &= 1<<(n%64) - 1
+		}
+		seg.build()
+
+		for b := countPhiPrimes; b < len(c.primes); b++ {
+			p := uint64(c.primes[b])
+			if b < int(hard) {
+				// Step 3a: the hard leaves with n < high
+				lo := y / p
+				j := next[b]
+				seg.reset()
+				for ; j < len(ms) && uint64(ms[j]) > lo; j++ {
+					m := uint64(ms[j])
+					if uint64(c.lpf[m]) <= p {
+						continue
+					}
+					n := x / (p * m)
+					if n >= high {
+						break
+					}
+					if v := phi[b] + seg.count(n); c.mu[m] > 0 {
+						s2 -= v
+					} else {
+						s2 += v
+					}
+				}
+				next[b] = j
+				phi[b] += seg.total
+			}
+			// Step 3b: sieve by p_{b+1}, once the leaves
+			// are done only to find the primes for P2
+			if b+1 < int(hard) {
+				seg.remove(p)
+			} else if p*p < high {
+				seg.cross(p, p*p)
+			} else {
+				break
+			}
+		}
+
+		// Step 3c: numbers above sqrt(x) left in the segment are prime
+		for ; i >= int(a) && x/q < high; i, q = i-1, c.below(q) {
+			v := x / q
+			if v > u {
+				pi += seg.between(u, v)
+				u = v
+			}
+			p2 += pi - uint64(i)
+		}
+		if high-1 > u {
+			pi += seg.between(u, high-1)
+			u = high - 1
+		}
+	}
+	return
+}
+
+// segment is a sieve of the odd numbers low, low+2, ... with
+// the number of bits set in each block of countBlock words.
+type segment struct {
+	low    uint64
+	words  []uint64
+	counts []uint32
+	total  uint64
+	// count goes on from block at, with before bits set below it
+	at     int
+	before uint64
+}
+
+// build fills in the counts from the words.
+func (s *segment) build() {
+	clear(s.counts)
+	s.total = 0
+	for i, w := range s.words {
+		n := bits.OnesCount64(w)
+		s.counts[i/countBlock] += uint32(n)
+		s.total += uint64(n)
+	}
+}
+
+// first returns the index of the first odd multiple m >= start of p.
+func (s *segment) first(p, start uint64) uint64 {
+	if start < s.low {
+		start = (s.low + p - 1) / p * p
+		if start%2 == 0 {
+			start += p
+		}
+	}
+	return (start - s.low) / 2
+}
+
+// cross clears the odd multiples m >= start of p.
+func (s *segment) cross(p, start uint64) {
+	end := 64 * uint64(len(s.words))
+	for m := s.first(p, start); m < end; m += p {
+		s.words[m/64] &^= 1 << (m % 64)
+	}
+}
+
+// remove clears the odd multiples m >= p of p
+// and keeps the counts up to date.
+func (s *segment) remove(p uint64) {
+	end := 64 * uint64(len(s.words))
+	for m := s.first(p, p); m < end; m += p {
+		w, bit := m/64, uint64(1)<<(m%64)
+		if s.words[w]&bit != 0 {
+			s.words[w] &^= bit
+			s.counts[w/countBlock]--
+			s.total--
+		}
+	}
+}
+
+// reset starts count over from low.
+func (s *segment) reset() {
+	s.at, s.before = 0, 0
+}
+
+// count returns the number of bits set for low <= m <= n,
+// n must not go down between calls to reset.
+func (s *segment) count(n uint64) uint64 {
+	k := (n - s.low + 2) / 2
+	w := int(k / 64)
+	for ; (s.at+1)*countBlock <= w; s.at++ {
+		s.before += uint64(s.counts[s.at])
+	}
+	sum := s.before
+	for _, v := range s.words[s.at*countBlock : w] {
+		sum += uint64(bits.OnesCount64(v))
+	}
+	if k%64 != 0 {
+		sum += uint64(bits.OnesCount64(s.words[w] << (64 - k%64)))
+	}
+	return sum
+}
+
+// between returns the number of bits set for u < m <= v.
+func (s *segment) between(u, v uint64) (n uint64) {
+	i, j := (u-s.low+2)/2, (v-s.low+2)/2
+	for ; i < j && i%64 != 0; i++ {
+		n += s.words[i/64] >> (i % 64) & 1
+	}
+	for ; i+64 <= j; i += 64 {
+		n += uint64(bits.OnesCount64(s.words[i/64]))
+	}
+	for ; i < j; i++ {
+		n += s.words[i/64] >> (i % 64) & 1
+	}
+	return
+}
+
+// cbrt64 returns floor(cbrt(n)).
+func cbrt64(n uint64) uint64 {
+	r := uint64(math.Cbrt(float64(n)))
+	// float64 may be off by one either way
+	for r > 0 && r*r*r > n {
+		r--
+	}
+	for r < 2642245 && (r+1)*(r+1)*(r+1) <= n {
+		r++
+	}
+	return r
+}
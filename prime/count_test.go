@@ -0,0 +1,55 @@
+package prime
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		x, pi uint64
+	}{
+		{0, 0},
+		{1, 0},
+		{2, 1},
+		{3, 2},
+		{100, 25},
+		{1 << 20, 82025},
+		{1<<20 + 7, 82026},
+		{10000000, 664579},
+		{100000000, 5761455},
+		{1000000000, 50847534},
+		{1 << 32, 203280221},
+		{10000000000, 455052511},
+		{100000000000, 4118054813},
+		{1000000000000, 37607912018},
+		{1 << 40, 41203088796},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.pi, Count(test.x), "pi(%d)", test.x)
+	}
+}
+
+func TestCountSieve(t *testing.T) {
+	// pi(x) at and just below each prime in a few windows
+	for _, lo := range []uint64{1<<20 - 100, 3000000, 25000000} {
+		pi, prev := Count(lo-1), lo-1
+		Sieve(lo, lo+2000, func(p uint64) bool {
+			for x := p - 1; x > p-4 && x > prev; x-- {
+				assert.Equal(t, pi, Count(x), "pi(%d)", x)
+			}
+			pi, prev = pi+1, p
+			assert.Equal(t, pi, Count(p), "pi(%d)", p)
+			return true
+		})
+	}
+}
+
+func TestCountContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := CountContext(ctx, 1e15)
+	assert.Error(t, err)
+}
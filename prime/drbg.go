@@ -0,0 +1,72 @@
+package prime
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// the most bytes HMAC_DRBG gives per generate call
+const drbgMaxRequest = 1 << 16
+
+// DRBG is a deterministic random bit generator, namely
+// HMAC_DRBG with SHA-256 from NIST SP 800-90A without
+// reseeding. The same seed always gives the same bytes
+// for the same sequence of reads, so Prime(NewDRBG(seed), bits)
+// gives reproducible primes. It is not meant for secrets
+// unless the seed is.
+type DRBG struct {
+	k, v []byte
+}
+
+// NewDRBG returns a DRBG instantiated with seed.
+func NewDRBG(seed []byte) *DRBG {
+	d := &DRBG{
+		k: make([]byte, sha256.Size),
+		v: make([]byte, sha256.Size),
+	}
+	for i := range d.v {
+		d.v[i] = 1
+	}
+	d.update(seed)
+	return d
+}
+
+// Read fills p with pseudorandom bytes. It never fails.
+func (d *DRBG) Read(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		m := min(len(p), drbgMaxRequest)
+		d.generate(p[:m])
+		p = p[m:]
+		n += m
+	}
+	return
+}
+
+// generate is the HMAC_DRBG generate process.
+func (d *DRBG) generate(p []byte) {
+	for len(p) > 0 {
+		d.v = d.hmac(d.v)
+		p = p[copy(p, d.v):]
+	}
+	d.update(nil)
+}
+
+// update is the HMAC_DRBG update process.
+func (d *DRBG) update(data []byte) {
+	d.k = d.hmac(d.v, []byte{0}, data)
+	d.v = d.hmac(d.v)
+	if len(data) == 0 {
+		return
+	}
+	d.k = d.hmac(d.v, []byte{1}, data)
+	d.v = d.hmac(d.v)
+}
+
+// hmac returns HMAC(k, data[0] || data[1] || ...).
+func (d *DRBG) hmac(data ...[]byte) []byte {
+	h := hmac.New(sha256.New, d.k)
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
@@ -0,0 +1,74 @@
+package prime
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDRBG(t *testing.T) {
+	// NIST CAVP HMAC_DRBG SHA-256 test vector, no prediction
+	// resistance, no personalization string: instantiate with
+	// entropy || nonce, generate twice and return the second
+	seed, _ := hex.DecodeString("ca851911349384bffe89de1cbdc46e6831e44d34a4fb935ee285dd14b71a7488" +
+		"659ba96c601dc69fc902940805ec0ca8")
+	want := "e528e9abf2dece54d47c7e75e5fe302149f817ea9fb4bee6f4199697d04d5b89" +
+		"d54fbb978a15b5c443c9ec21036d2460b6f73ebad0dc2aba6e624abf07745bc1" +
+		"07694bb7547bb0995f70de25d6b29e2d3011bb19d27676c07162c8b5ccde0668" +
+		"961df86803482cb37ed6d5c0bb8d50cf1f50d476aa0458bdaba806f48be9dcb8"
+	d := NewDRBG(seed)
+	b := make([]byte, 128)
+	d.Read(b)
+	n, err := d.Read(b)
+	require.NoError(t, err)
+	assert.Equal(t, 128, n)
+	assert.Equal(t, want, hex.EncodeToString(b))
+}
+
+func TestPrimeSeeded(t *testing.T) {
+	// these must never change so seeded primes are reproducible
+	p, err := Prime(NewDRBG([]byte("test")), 128)
+	require.NoError(t, err)
+	assert.Equal(t, "321741063576312077109338577380925218017", p.String())
+	p, err = Prime(NewDRBG([]byte("test")), 64)
+	require.NoError(t, err)
+	assert.Equal(t, "17441618005361717747", p.String())
+	for _, bits := range []int{2, 10, 100, 1000} {
+		p, err := Prime(NewDRBG([]byte("seed")), bits)
+		require.NoError(t, err)
+		q, err := Prime(NewDRBG([]byte("seed")), bits)
+		require.NoError(t, err)
+		assert.Equal(t, p, q)
+		assert.Equal(t, bits, p.BitLen())
+	}
+	p, err = SafePrime(NewDRBG([]byte("test")), 64)
+	require.NoError(t, err)
+	assert.Equal(t, "16436491937013884207", p.String())
+	_, err = Prime(NewDRBG(nil), 1)
+	assert.Error(t, err)
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("no randomness") }
+
+func TestPrimeReaderError(t *testing.T) {
+	_, err := Prime(errReader{}, 8)
+	assert.Error(t, err)
+	_, err = Prime(errReader{}, 128)
+	assert.Error(t, err)
+	_, err = SafePrime(errReader{}, 512)
+	assert.Error(t, err)
+	_, err = StrongPrime(errReader{}, 512)
+	assert.Error(t, err)
+	_, err = SolovayStrassenFrom(errReader{}, big.NewInt(1009), 10)
+	assert.Error(t, err)
+	_, err = GenerateRSAKey(errReader{}, 2048, 2)
+	assert.Error(t, err)
+	_, err = GenerateDSAParams(errReader{}, 1024, 160)
+	assert.Error(t, err)
+}
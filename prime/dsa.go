@@ -0,0 +1,152 @@
+package prime
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// DSAParams are domain parameters (P, Q, G) for DSA or
+// a Schnorr group: Q is a prime dividing P-1 and G
+// generates the subgroup of order Q mod P. Seed, Counter
+// and Index let anyone check that P, Q and G were
+// generated as in FIPS 186-4, appendix A.1.1.2 and A.2.3.
+type DSAParams struct {
+	P       *big.Int `json:"p"`
+	Q       *big.Int `json:"q"`
+	G       *big.Int `json:"g"`
+	Seed    []byte   `json:"seed"`
+	Counter int      `json:"counter"`
+	Index   byte     `json:"index"`
+}
+
+// GenerateDSAParams returns domain parameters with an
+// L bit prime P and an N bit prime Q dividing P-1, using
+// seeds read from random. The usual sizes are (L, N) =
+// (1024, 160), (2048, 224), (2048, 256) and (3072, 256).
+func GenerateDSAParams(random io.Reader, L, N int) (*DSAParams, error) {
+	// Step 0: parse input
+	if N < 16 || N > 8*sha256.Size || L <= N {
+		return nil, fmt.Errorf("prime: unsupported DSA sizes L=%d, N=%d", L, N)
+	}
+	// Step 1: find p and q from a random seed
+	seed := make([]byte, (N+7)/8)
+	for {
+		if _, err := io.ReadFull(random, seed); err != nil {
+			return nil, err
+		}
+		if P, Q, counter := dsaPrimes(L, N, seed, 4*L); P != nil {
+			d := &DSAParams{P: P, Q: Q, Seed: seed, Counter: counter, Index: 1}
+			// Step 2: find a generator
+			if d.G = dsaGenerator(P, Q, seed, d.Index); d.G == nil {
+				return nil, errors.New("prime: no DSA generator found")
+			}
+			return d, nil
+		}
+	}
+}
+
+// VerifyDSAParams checks that d was generated from its seed,
+// counter and index as in GenerateDSAParams.
+// See FIPS 186-4, appendix A.1.1.3 and A.2.4.
+func VerifyDSAParams(d *DSAParams) bool {
+	// Step 0: parse input
+	if d == nil || d.P == nil || d.Q == nil || d.G == nil || d.Counter < 0 {
+		return false
+	}
+	L, N := d.P.BitLen(), d.Q.BitLen()
+	if N < 16 || N > 8*sha256.Size || L <= N || 8*len(d.Seed) < N || d.Counter >= 4*L {
+		return false
+	}
+	// Step 1: regenerate p and q
+	P, Q, counter := dsaPrimes(L, N, d.Seed, d.Counter+1)
+	if P == nil || counter != d.Counter || P.Cmp(d.P) != 0 || Q.Cmp(d.Q) != 0 {
+		return false
+	}
+	// Step 2: check g is in the subgroup and regenerate it
+	if d.G.Cmp(two) < 0 || d.G.Cmp(d.P) >= 0 {
+		return false
+	}
+	if new(big.Int).Exp(d.G, d.Q, d.P).Cmp(one) != 0 {
+		return false
+	}
+	G := dsaGenerator(d.P, d.Q, d.Seed, d.Index)
+	return G != nil && G.Cmp(d.G) == 0
+}
+
+// dsaPrimes follows FIPS 186-4, appendix A.1.1.2 with SHA-256
+// from step 6 using the given seed. It returns nil if the
+// seed does not give a prime q or no prime p is found
+// in the first tries counters.
+func dsaPrimes(L, N int, seed []byte, tries int) (P, Q *big.Int, counter int) {
+	// Step 3,4: p is built from n+1 hashes, the last one b bits
+	outlen := 8 * sha256.Size
+	n := (L+outlen-1)/outlen - 1
+	b := L - 1 - n*outlen
+
+	// Step 6,7: q = 2^(N-1) + U + 1 - (U mod 2)
+	// where U = Hash(seed) mod 2^(N-1)
+	h := sha256.Sum256(seed)
+	U := new(big.Int).SetBytes(h[:])
+	top := new(big.Int).Lsh(one, uint(N-1))
+	Q = U.Mod(U, top)
+	Q.Add(Q, top).SetBit(Q, 0, 1)
+
+	// Step 8,9: test q
+	if BPSW(Q) == IsComposite {
+		return nil, nil, 0
+	}
+
+	// Step 10,11: search for p
+	seedlen := 8 * len(seed)
+	mod := new(big.Int).Lsh(one, uint(seedlen))
+	s := new(big.Int).SetBytes(seed)
+	Lbit := new(big.Int).Lsh(one, uint(L-1))
+	q2 := new(big.Int).Lsh(Q, 1)
+	W, V, c := new(big.Int), new(big.Int), new(big.Int)
+	buf := make([]byte, len(seed))
+	for counter = 0; counter < tries; counter++ {
+		// Step 11.1,11.2: W = V_0 + V_1 2^outlen + ... + (V_n mod 2^b) 2^(n outlen)
+		// where V_j = Hash((seed + offset + j) mod 2^seedlen)
+		W.SetInt64(0)
+		for j := 0; j <= n; j++ {
+			s.Add(s, one).Mod(s, mod)
+			h = sha256.Sum256(s.FillBytes(buf))
+			V.SetBytes(h[:])
+			if j == n {
+				V.Mod(V, c.Lsh(one, uint(b)))
+			}
+			W.Add(W, V.Lsh(V, uint(j*outlen)))
+		}
+		// Step 11.3-11.5: X = W + 2^(L-1), p = X - (X mod 2q - 1)
+		P = W.Add(W, Lbit)
+		P.Sub(P, c.Mod(P, q2)).Add(P, one)
+		// Step 11.6-11.8: test p
+		if P.Cmp(Lbit) >= 0 && BPSW(P) != IsComposite {
+			return P, Q, counter
+		}
+	}
+	return nil, nil, 0
+}
+
+// dsaGenerator follows FIPS 186-4, appendix A.2.3 to
+// compute g = Hash(seed || "ggen" || index || count)^((p-1)/q)
+// mod p. It returns nil if the 16 bit count runs out.
+func dsaGenerator(P, Q *big.Int, seed []byte, index byte) *big.Int {
+	e := new(big.Int).Sub(P, one)
+	e.Quo(e, Q)
+	U := bytes.Join([][]byte{seed, []byte("ggen"), {index}, {0, 0}}, nil)
+	G := new(big.Int)
+	for count := 1; count < 1<<16; count++ {
+		U[len(U)-2], U[len(U)-1] = byte(count>>8), byte(count)
+		h := sha256.Sum256(U)
+		G.Exp(G.SetBytes(h[:]), e, P)
+		if G.Cmp(two) >= 0 {
+			return G
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package prime
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateDSAParams(t *testing.T) {
+	cases := []struct {
+		L, N int
+	}{
+		{512, 160},
+		{1024, 160},
+		{1000, 100},
+		{2048, 256},
+	}
+	for _, c := range cases {
+		msg := fmt.Sprintf("L=%d, N=%d", c.L, c.N)
+		d, err := GenerateDSAParams(rand.Reader, c.L, c.N)
+		require.NoError(t, err, msg)
+		assert.Equal(t, c.L, d.P.BitLen(), msg)
+		assert.Equal(t, c.N, d.Q.BitLen(), msg)
+		assert.NotEqual(t, IsComposite, BPSW(d.P), msg)
+		assert.NotEqual(t, IsComposite, BPSW(d.Q), msg)
+		assert.Equal(t, 0, new(big.Int).Mod(new(big.Int).Sub(d.P, one), d.Q).Sign(), msg)
+		assert.Equal(t, one, new(big.Int).Exp(d.G, d.Q, d.P), msg)
+		assert.True(t, VerifyDSAParams(d), msg)
+	}
+	_, err := GenerateDSAParams(rand.Reader, 2048, 512)
+	assert.Error(t, err)
+	_, err = GenerateDSAParams(rand.Reader, 160, 160)
+	assert.Error(t, err)
+}
+
+func TestVerifyDSAParams(t *testing.T) {
+	d, err := GenerateDSAParams(rand.Reader, 512, 160)
+	require.NoError(t, err)
+	require.True(t, VerifyDSAParams(d))
+
+	// survives a round trip through JSON
+	b, err := json.Marshal(d)
+	require.NoError(t, err)
+	var d2 DSAParams
+	require.NoError(t, json.Unmarshal(b, &d2))
+	assert.True(t, VerifyDSAParams(&d2))
+
+	bad := *d
+	bad.Counter++
+	assert.False(t, VerifyDSAParams(&bad))
+	bad = *d
+	bad.Index++
+	assert.False(t, VerifyDSAParams(&bad))
+	bad = *d
+	bad.Seed = append([]byte(nil), d.Seed...)
+	bad.Seed[0] ^= 1
+	assert.False(t, VerifyDSAParams(&bad))
+	bad = *d
+	bad.G = new(big.Int).Exp(d.G, two, d.P)
+	assert.False(t, VerifyDSAParams(&bad))
+	bad = *d
+	bad.P = new(big.Int).Add(d.P, new(big.Int).Lsh(d.Q, 1))
+	assert.False(t, VerifyDSAParams(&bad))
+	assert.False(t, VerifyDSAParams(&DSAParams{}))
+	assert.False(t, VerifyDSAParams(nil))
+}
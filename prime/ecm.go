@@ -0,0 +1,335 @@
+package prime
+
+import (
+	"context"
+	"math/big"
+	"math/bits"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// the giant step of ecmStage2, which starts after ecmD/2
+// so stage 1 also covers the primes up to there
+const ecmD = 210
+
+// ECM looks for a factor of N with Lenstra's elliptic curve
+// method, trying up to curves curves (forever if curves <= 0)
+// with stage 1 bound B1 and stage 2 bound B2. A curve finds a
+// prime p dividing N when the order of its group mod p is a
+// product of prime powers at most B1 and at most one more prime
+// in (B1, B2]. These orders are spread around p, so unlike
+// PMinus1 each new curve is a new chance. It returns nil if
+// no curve found a factor.
+//
+// The usual choices of B1 for a factor of d digits, with
+// B2 = 100 B1, and the expected number of curves are
+//
+//	d   B1        curves
+//	15  2000      25
+//	20  11000     90
+//	25  50000     300
+//	30  250000    700
+//	35  1000000   1800
+//	40  3000000   5100
+//
+// See Lenstra, "Factoring integers with elliptic curves" (1987),
+// the table is Zimmermann's from ECMNET.
+func ECM(N *big.Int, B1, B2 uint64, curves int) *big.Int {
+	d, _ := ECMContext(context.Background(), N, B1, B2, curves)
+	return d
+}
+
+// ECMContext is ECM but checks ctx while running each curve
+// and stops with ctx.Err() once it is done.
+func ECMContext(ctx context.Context, N *big.Int, B1, B2 uint64, curves int) (*big.Int, error) {
+	return ECMParallel(ctx, N, B1, B2, curves, 1)
+}
+
+// ECMParallel is ECMContext with the curves run by a pool of
+// workers, the first factor found wins and the rest are
+// cancelled. If workers <= 0 it uses GOMAXPROCS.
+func ECMParallel(ctx context.Context, N *big.Int, B1, B2 uint64, curves, workers int) (*big.Int, error) {
+	// Step 0: parse input / easy cases
+	if N.Cmp(big.NewInt(4)) < 0 {
+		return nil, nil
+	}
+	// the curves below need 2 and 3 to be invertible
+	for _, p := range []int64{2, 3} {
+		if d := big.NewInt(p); new(big.Int).Mod(N, d).Sign() == 0 {
+			return nontrivial(d, N), nil
+		}
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// Step 1: each worker takes the next curve and
+	// the first to find a factor cancels the rest
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var next atomic.Int64
+	var once sync.Once
+	var found *big.Int
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				c := next.Add(1) - 1
+				if curves > 0 && c >= int64(curves) {
+					return
+				}
+				// sigma = 6, 7, ... avoids the degenerate
+				// choices sigma = 0, 1, 3, 5
+				d, err := ecmCurve(ctx, N, big.NewInt(6+c), B1, B2)
+				if err != nil {
+					return
+				}
+				if d = nontrivial(d, N); d != nil {
+					once.Do(func() {
+						found = d
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if found != nil {
+		return found, nil
+	}
+	return nil, ctx.Err()
+}
+
+// montgomery is the curve B y^2 = x^3 + A x^2 + x mod N
+// where only a24 = (A + 2)/4 is needed to work with the
+// x coordinates X:Z of points. The point at infinity is 1:0.
+type montgomery struct {
+	N, a24 *big.Int
+	// scratch space
+	t1, t2, t3, t4 big.Int
+}
+
+// dbl sets X:Z to twice X1:Z1.
+func (m *montgomery) dbl(X, Z, X1, Z1 *big.Int) {
+	m.t1.Add(X1, Z1)
+	m.t1.Mul(&m.t1, &m.t1)
+	m.t1.Mod(&m.t1, m.N) // (X1 + Z1)^2
+	m.t2.Sub(X1, Z1)
+	m.t2.Mul(&m.t2, &m.t2)
+	m.t2.Mod(&m.t2, m.N)   // (X1 - Z1)^2
+	m.t3.Sub(&m.t1, &m.t2) // 4 X1 Z1
+	X.Mul(&m.t1, &m.t2)
+	X.Mod(X, m.N)
+	m.t4.Mul(m.a24, &m.t3)
+	m.t4.Add(&m.t4, &m.t2)
+	Z.Mul(&m.t3, &m.t4)
+	Z.Mod(Z, m.N)
+}
+
+// add sets X:Z to X1:Z1 + X2:Z2 given their difference
+// Xd:Zd, which must not be the point at infinity. X:Z may
+// be the same as X1:Z1 or X2:Z2.
+func (m *montgomery) add(X, Z, X1, Z1, X2, Z2, Xd, Zd *big.Int) {
+	m.t1.Sub(X1, Z1)
+	m.t2.Add(X2, Z2)
+	m.t1.Mul(&m.t1, &m.t2)
+	m.t1.Mod(&m.t1, m.N) // (X1 - Z1)(X2 + Z2)
+	m.t2.Add(X1, Z1)
+	m.t3.Sub(X2, Z2)
+	m.t2.Mul(&m.t2, &m.t3)
+	m.t2.Mod(&m.t2, m.N) // (X1 + Z1)(X2 - Z2)
+	m.t3.Add(&m.t1, &m.t2)
+	m.t3.Mul(&m.t3, &m.t3)
+	m.t4.Sub(&m.t1, &m.t2)
+	m.t4.Mul(&m.t4, &m.t4)
+	m.t4.Mod(&m.t4, m.N)
+	m.t3.Mul(&m.t3, Zd)
+	Z.Mul(&m.t4, Xd)
+	X.Mod(&m.t3, m.N)
+	Z.Mod(Z, m.N)
+}
+
+// mul sets X:Z to k X1:Z1 for k > 0 with the Montgomery ladder.
+func (m *montgomery) mul(X, Z, X1, Z1 *big.Int, k uint64) {
+	// k P = R0 and (k+1) P = R1 so R1 - R0 = P
+	X0, Z0 := new(big.Int).Set(X1), new(big.Int).Set(Z1)
+	Xr, Zr := new(big.Int), new(big.Int)
+	m.dbl(Xr, Zr, X0, Z0)
+	Xp, Zp := new(big.Int).Set(X1), new(big.Int).Set(Z1)
+	for i := bits.Len64(k) - 2; i >= 0; i-- {
+		if k>>uint(i)&1 == 1 {
+			m.add(X0, Z0, X0, Z0, Xr, Zr, Xp, Zp)
+			m.dbl(Xr, Zr, Xr, Zr)
+		} else {
+			m.add(Xr, Zr, X0, Z0, Xr, Zr, Xp, Zp)
+			m.dbl(X0, Z0, X0, Z0)
+		}
+	}
+	X.Set(X0)
+	Z.Set(Z0)
+}
+
+// ecmCurve runs stage 1 and stage 2 of ECM on the curve
+// from Suyama's parametrization with sigma, whose group
+// order is always divisible by 12. It returns the gcd of
+// N and the product of the stage 2 terms, or some other
+// divisor of N found on the way, which may be 1 or N.
+//
+// See Zimmermann and Dodson, "20 years of ECM" (2006).
+func ecmCurve(ctx context.Context, N, sigma *big.Int, B1, B2 uint64) (*big.Int, error) {
+	// Step 1: the curve and point from sigma, with
+	// u = sigma^2 - 5, v = 4 sigma, X:Z = u^3:v^3 and
+	// a24 = (v - u)^3 (3u + v) / (16 u^3 v)
+	u := new(big.Int).Mul(sigma, sigma)
+	u.Sub(u, big.NewInt(5))
+	u.Mod(u, N)
+	v := new(big.Int).Lsh(sigma, 2)
+	v.Mod(v, N)
+	X := new(big.Int).Exp(u, big.NewInt(3), N)
+	Z := new(big.Int).Exp(v, big.NewInt(3), N)
+	a24 := new(big.Int).Sub(v, u)
+	a24.Exp(a24, big.NewInt(3), N)
+	a24.Mul(a24, new(big.Int).Add(new(big.Int).Lsh(u, 1), new(big.Int).Add(u, v)))
+	den := new(big.Int).Lsh(X, 4)
+	den.Mul(den, v)
+	den.Mod(den, N)
+	if g := new(big.Int).GCD(nil, nil, den, N); g.Cmp(one) != 0 {
+		return g, nil
+	}
+	a24.Mul(a24, den.ModInverse(den, N))
+	a24.Mod(a24, N)
+	m := &montgomery{N: N, a24: a24}
+
+	// Step 2: multiply by every prime power at most B1,
+	// and by the primes up to ecmD/2 which stage 2 skips
+	n := 0
+	var err error
+	Sieve(2, max(B1, ecmD/2)+1, func(q uint64) bool {
+		if n++; n%1024 == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+		Q := q
+		for Q <= B1/q {
+			Q *= q
+		}
+		m.mul(X, Z, X, Z, Q)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if g := new(big.Int).GCD(nil, nil, Z, N); g.Cmp(one) != 0 {
+		return g, nil
+	}
+
+	// Step 3: one more prime in (B1, B2]
+	return ecmStage2(ctx, m, X, Z, B1, B2)
+}
+
+// ecmStage2 is lucasStage2 for the point X:Z. With D = 210
+// every prime q in (B1, B2] above D/2 is kD - j or kD + j
+// for some 0 < j < D/2 coprime to D, and since -P and P
+// have the same x coordinate q P = 0 mod p exactly when
+// x(kD P) = x(j P) mod p for one of them, so pairs of primes
+// share a single term X_kD - x_j Z_kD. It returns the gcd of
+// N and the product of the terms.
+func ecmStage2(ctx context.Context, m *montgomery, X, Z *big.Int, B1, B2 uint64) (*big.Int, error) {
+	const D = ecmD
+	N := m.N
+	// Step 0: parse input / easy cases
+	if B2 <= B1 {
+		return big.NewInt(1), nil
+	}
+
+	// Step 1: baby steps j P for odd j < D/2 using
+	// (j + 2) P = j P + 2 P with difference (j - 2) P,
+	// normalized to Z = 1 so each term is one product
+	var baby [D / 2]*big.Int
+	X2, Z2 := new(big.Int), new(big.Int)
+	m.dbl(X2, Z2, X, Z)
+	Xj, Zj := new(big.Int).Set(X), new(big.Int).Set(Z)
+	Xm, Zm := new(big.Int).Set(X), new(big.Int).Set(Z) // (j - 2) P
+	for j := 1; j < D/2; j += 2 {
+		if j%3 != 0 && j%5 != 0 && j%7 != 0 {
+			inv := new(big.Int).ModInverse(Zj, N)
+			if inv == nil {
+				return new(big.Int).GCD(nil, nil, Zj, N), nil
+			}
+			baby[j] = inv.Mul(inv, Xj)
+			baby[j].Mod(baby[j], N)
+		}
+		Xn, Zn := new(big.Int), new(big.Int)
+		if j == 1 {
+			// 3P = 2P + P with difference P
+			m.add(Xn, Zn, X2, Z2, Xj, Zj, X, Z)
+		} else {
+			m.add(Xn, Zn, Xj, Zj, X2, Z2, Xm, Zm)
+		}
+		Xm, Zm, Xj, Zj = Xj, Zj, Xn, Zn
+	}
+
+	// Step 2: giant steps kD P starting with k >= 1
+	lo := max(B1+1, D/2+1)
+	k := (lo + D/2) / D
+	XD, ZD := new(big.Int), new(big.Int)
+	m.mul(XD, ZD, X, Z, D)
+	Xk, Zk := new(big.Int), new(big.Int)
+	m.mul(Xk, Zk, XD, ZD, k)
+	Xkm1, Zkm1 := new(big.Int), new(big.Int)
+	if k > 1 {
+		m.mul(Xkm1, Zkm1, XD, ZD, k-1)
+	}
+	step := func() {
+		Xn, Zn := new(big.Int), new(big.Int)
+		if k == 1 {
+			m.dbl(Xn, Zn, XD, ZD)
+		} else {
+			m.add(Xn, Zn, Xk, Zk, XD, ZD, Xkm1, Zkm1)
+		}
+		Xkm1, Zkm1, Xk, Zk = Xk, Zk, Xn, Zn
+		k++
+	}
+
+	// Step 3: multiply X_kD - x_j Z_kD for each
+	// prime q = kD +- j, skipping the second of a pair
+	g := big.NewInt(1)
+	acc := big.NewInt(1)
+	z := new(big.Int)
+	var done [D / 2]bool
+	n := 0
+	var err error
+	Sieve(lo, B2+1, func(q uint64) bool {
+		for k < (q+D/2)/D {
+			step()
+			done = [D / 2]bool{}
+		}
+		j := q - k*D
+		if q < k*D {
+			j = k*D - q
+		}
+		if done[j] {
+			return true
+		}
+		done[j] = true
+		z.Mul(baby[j], Zk)
+		acc.Mul(acc, z.Sub(Xk, z))
+		acc.Mod(acc, N)
+		if n++; n%1024 == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+			g.GCD(nil, nil, acc, N)
+		}
+		return g.Cmp(one) == 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g.GCD(nil, nil, acc, N), nil
+}
@@ -0,0 +1,90 @@
+package prime
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMontgomery(t *testing.T) {
+	N := new(big.Int).Mul(rough62, rough64)
+	m := &montgomery{N: N, a24: big.NewInt(12345)}
+	X, Z := big.NewInt(7), big.NewInt(1)
+	same := func(X1, Z1, X2, Z2 *big.Int) bool {
+		a := new(big.Int).Mul(X1, Z2)
+		b := new(big.Int).Mul(X2, Z1)
+		return a.Sub(a, b).Mod(a, N).Sign() == 0
+	}
+	// k P by adding P to (k-1) P each time
+	Xk, Zk := new(big.Int).Set(X), new(big.Int).Set(Z)
+	Xm, Zm := new(big.Int), new(big.Int)
+	m.dbl(Xm, Zm, X, Z)
+	Xm, Zm, Xk, Zk = Xk, Zk, Xm, Zm
+	for k := uint64(2); k < 100; k++ {
+		Xa, Za := new(big.Int), new(big.Int)
+		m.mul(Xa, Za, X, Z, k)
+		require.True(t, same(Xa, Za, Xk, Zk), fmt.Sprintf("k=%d", k))
+		Xn, Zn := new(big.Int), new(big.Int)
+		m.add(Xn, Zn, Xk, Zk, X, Z, Xm, Zm)
+		Xm, Zm, Xk, Zk = Xk, Zk, Xn, Zn
+	}
+	// (ab) P = a (b P)
+	Xa, Za := new(big.Int), new(big.Int)
+	m.mul(Xa, Za, X, Z, 1001*999983)
+	Xb, Zb := new(big.Int), new(big.Int)
+	m.mul(Xb, Zb, X, Z, 999983)
+	m.mul(Xb, Zb, Xb, Zb, 1001)
+	assert.True(t, same(Xa, Za, Xb, Zb))
+}
+
+func TestECM(t *testing.T) {
+	p40 := big.NewInt(1<<40 - 87)
+	cases := []struct {
+		N    *big.Int
+		want *big.Int
+	}{
+		{big.NewInt(3), nil},
+		{big.NewInt(1 << 20), big.NewInt(2)},
+		{big.NewInt(3 * 65537), big.NewInt(3)},
+		{rough64, nil},
+		{new(big.Int).Mul(p40, rough64), p40},
+		{new(big.Int).Mul(p40, new(big.Int).Mul(rough62, rough64)), p40},
+	}
+	for _, c := range cases {
+		d := ECM(c.N, 2000, 200000, 100)
+		assert.Equal(t, c.want, d, fmt.Sprintf("N=%d", c.N))
+	}
+	N := new(big.Int).Mul(p40, rough64)
+	d, err := ECMParallel(context.Background(), N, 2000, 200000, 0, 4)
+	require.NoError(t, err)
+	assert.Equal(t, p40, d)
+	// no curve finds the 62 and 64 bit factors this quickly
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ECMContext(ctx, new(big.Int).Mul(rough62, rough64), 1000000, 100000000, 0)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestECMCurve(t *testing.T) {
+	// the start point mod 10007 has order q m with q in
+	// (B1, ecmD/2] and m a product of prime powers at most B1
+	p := big.NewInt(10007)
+	N := new(big.Int).Mul(p, rough64)
+	for _, c := range []struct {
+		sigma  int64
+		B1, B2 uint64
+	}{
+		{7, 12, 1000},  // 9 * 31
+		{23, 12, 1000}, // 5 * 83
+		{23, 12, 12},
+		{7, 30, 31},
+	} {
+		d, err := ecmCurve(context.Background(), N, big.NewInt(c.sigma), c.B1, c.B2)
+		require.NoError(t, err)
+		assert.Equal(t, p, d, fmt.Sprintf("sigma=%d, B1=%d, B2=%d", c.sigma, c.B1, c.B2))
+	}
+}
@@ -0,0 +1,397 @@
+package prime
+
+import (
+	"math/big"
+)
+
+// numbers with at most this many bits are
+// proven prime with SimpleProof instead
+const ecppSmallBits = 32
+
+// ECPP is an elliptic curve primality certificate for N.
+// Each step proves its N is prime as long as its Q is,
+// the Q of one step is the N of the next, and the Q of
+// the final step is small enough to check with SimpleProof.
+//
+// See https://en.wikipedia.org/wiki/Elliptic_curve_primality
+type ECPP struct {
+	N     *big.Int
+	Steps []ECPPStep
+}
+
+// ECPPStep is one step of an ECPP certificate.
+// The point P = (X,Y) on y^2 = x^3 + Ax + B mod N
+// satisfies (M/Q)P != 0 and MP = 0 where Q is a
+// prime larger than (N^(1/4) + 1)^2.
+// By the Goldwasser-Kilian theorem N is then prime.
+type ECPPStep struct {
+	N, A, B, X, Y, M, Q *big.Int
+}
+
+// ECPPCertificate uses the Atkin-Morain elliptic curve
+// primality proving algorithm to prove N is prime.
+// It returns nil if N is composite or if no curve
+// was found with a discriminant in our search space.
+//
+// See Cohen's book, Ch. 9, algorithm 9.2.4.
+func ECPPCertificate(N *big.Int) *ECPP {
+	// Step 0: parse input / easy cases
+	if N.Sign() <= 0 {
+		return nil
+	}
+	c := &ECPP{N: new(big.Int).Set(N)}
+	if N.BitLen() <= ecppSmallBits {
+		if SimpleProof(N) {
+			return c
+		}
+		return nil
+	}
+	if BPSW(N) == IsComposite {
+		return nil
+	}
+
+	// Step 1: find a chain of curves that reduces
+	// N to smaller and smaller probable primes,
+	// backing up a step whenever we hit a dead end
+	n := c.N
+	deadEnds := make(map[string]bool)
+	for n.BitLen() > ecppSmallBits || !SimpleProof(n) {
+		var s *ECPPStep
+		if n.BitLen() > ecppSmallBits {
+			s = ecppStep(n, deadEnds)
+		}
+		if s != nil {
+			c.Steps = append(c.Steps, *s)
+			n = s.Q
+			continue
+		}
+		if len(c.Steps) == 0 {
+			return nil
+		}
+		deadEnds[n.String()] = true
+		c.Steps = c.Steps[:len(c.Steps)-1]
+		n = c.N
+		if len(c.Steps) > 0 {
+			n = c.Steps[len(c.Steps)-1].Q
+		}
+	}
+	return c
+}
+
+// VerifyECPP checks that c is a valid ECPP certificate.
+func VerifyECPP(c *ECPP) bool {
+	if c == nil || c.N == nil {
+		return false
+	}
+	n := c.N
+	for _, s := range c.Steps {
+		if s.N == nil || s.N.Cmp(n) != 0 || !verifyECPPStep(s) {
+			return false
+		}
+		n = s.Q
+	}
+	return n.Sign() > 0 && n.BitLen() <= ecppSmallBits && SimpleProof(n)
+}
+
+func verifyECPPStep(s ECPPStep) bool {
+	// Step 0: check inputs
+	for _, x := range []*big.Int{s.N, s.A, s.B, s.X, s.Y, s.M, s.Q} {
+		if x == nil || x.Sign() < 0 {
+			return false
+		}
+	}
+	N := s.N
+	// need gcd(N,6) = 1
+	if N.Bit(0) == 0 || new(big.Int).Mod(N, big.NewInt(3)).Sign() == 0 {
+		return false
+	}
+
+	// Step 1: the curve is nonsingular, i.e.
+	// gcd(4A^3 + 27B^2, N) = 1
+	z := new(big.Int)
+	disc := new(big.Int).Exp(s.A, big.NewInt(3), N)
+	disc.Lsh(disc, 2)
+	disc.Add(disc, z.Mul(big.NewInt(27), z.Mul(s.B, s.B)))
+	if z.GCD(nil, nil, disc.Mod(disc, N), N).Cmp(one) != 0 {
+		return false
+	}
+
+	// Step 2: P is on the curve
+	if !onCurve(s.X, s.Y, s.A, s.B, N) {
+		return false
+	}
+
+	// Step 3: Q is big enough and divides M
+	if s.Q.Cmp(ecppBound(N)) == -1 {
+		return false
+	}
+	k, r := new(big.Int).QuoRem(s.M, s.Q, new(big.Int))
+	if r.Sign() != 0 {
+		return false
+	}
+
+	// Step 4: kP != 0 and QkP = 0
+	P := &ecPoint{new(big.Int).Mod(s.X, N), new(big.Int).Mod(s.Y, N)}
+	P, ok := ecMul(P, k, s.A, N)
+	if !ok || P == nil {
+		return false
+	}
+	P, ok = ecMul(P, s.Q, s.A, N)
+	return ok && P == nil
+}
+
+// returns (N^(1/4) + 2)^2 which is larger than (N^(1/4) + 1)^2
+func ecppBound(N *big.Int) *big.Int {
+	b := new(big.Int).Sqrt(N)
+	b.Sqrt(b)
+	b.Add(b, two)
+	return b.Mul(b, b)
+}
+
+// ecppStep finds a curve mod N whose order is a small number
+// times a probable prime q not in skip, or returns nil if none is found.
+func ecppStep(N *big.Int, skip map[string]bool) *ECPPStep {
+	bound := ecppBound(N)
+	Np1 := new(big.Int).Add(N, one)
+	for _, D := range cmDiscriminants() {
+		// Step 1: need 4N = u^2 + |D|v^2 which
+		// only happens if D is a square mod N
+		if big.Jacobi(new(big.Int).Mod(big.NewInt(int64(D)), N), N) != 1 {
+			continue
+		}
+		u, v := cornacchia(D, N)
+		if u == nil {
+			continue
+		}
+
+		// Step 2: check possible orders N + 1 - t
+		// for the traces t of curves with CM by D
+		for _, t := range cmTraces(D, u, v) {
+			m := new(big.Int).Sub(Np1, t)
+			k, q := splitSmooth(m)
+			if q.Cmp(bound) == -1 || q.Cmp(N) != -1 || skip[q.String()] || BPSW(q) == IsComposite {
+				continue
+			}
+
+			// Step 3: find a curve and a point that prove it
+			if s := ecppCurve(N, D, k, q); s != nil {
+				s.M = m
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// cornacchia solves 4N = u^2 + |D|v^2 for prime N
+// or returns nil, nil if there is no solution.
+// See Cohen's book, Ch. 1, algorithm 1.5.3.
+func cornacchia(D int, N *big.Int) (u, v *big.Int) {
+	x := new(big.Int).Mod(big.NewInt(int64(D)), N)
+	if x.ModSqrt(x, N) == nil {
+		return nil, nil
+	}
+	if x.Bit(0) != uint(D&1) {
+		x.Sub(N, x)
+	}
+	N4 := new(big.Int).Lsh(N, 2)
+	a := new(big.Int).Lsh(N, 1)
+	b := x
+	l := new(big.Int).Sqrt(N4)
+	for b.Cmp(l) == 1 {
+		a.Mod(a, b)
+		a, b = b, a
+	}
+	c := new(big.Int).Sub(N4, new(big.Int).Mul(b, b))
+	c, r := c.QuoRem(c, big.NewInt(int64(-D)), new(big.Int))
+	if r.Sign() != 0 || !IsSquare(c) {
+		return nil, nil
+	}
+	return b, c.Sqrt(c)
+}
+
+// cmTraces returns the possible traces t of Frobenius for
+// curves mod N with CM by D, given 4N = u^2 + |D|v^2.
+func cmTraces(D int, u, v *big.Int) (T []*big.Int) {
+	T = append(T, u)
+	switch D {
+	case -4:
+		T = append(T, new(big.Int).Lsh(v, 1))
+	case -3:
+		v3 := new(big.Int).Mul(v, big.NewInt(3))
+		T = append(T,
+			new(big.Int).Rsh(new(big.Int).Add(u, v3), 1),
+			new(big.Int).Rsh(new(big.Int).Sub(u, v3), 1))
+	}
+	for i := range T {
+		T = append(T, new(big.Int).Neg(T[i]))
+	}
+	return
+}
+
+// ecppCurve finds a curve with CM by D mod N of order k*q
+// and a point on it proving N is prime if q is.
+func ecppCurve(N *big.Int, D int, k, q *big.Int) *ECPPStep {
+	// Step 1: list the curves to try, for j = 0, 1728
+	// there are many twists so try a few
+	var curves [][2]*big.Int
+	switch D {
+	case -3:
+		for b := int64(1); b <= 32; b++ {
+			curves = append(curves, [2]*big.Int{big.NewInt(0), big.NewInt(b)})
+		}
+	case -4:
+		for a := int64(1); a <= 32; a++ {
+			curves = append(curves, [2]*big.Int{big.NewInt(a), big.NewInt(0)})
+		}
+	default:
+		j := rootModN(hilbertClassPolynomial(D), N)
+		if j == nil {
+			return nil
+		}
+		// c = j/(1728 - j) and y^2 = x^3 + 3cx + 2c has j-invariant j
+		c := new(big.Int).Sub(big.NewInt(1728), j)
+		if c.ModInverse(c.Mod(c, N), N) == nil {
+			return nil
+		}
+		c.Mul(c, j)
+		c.Mod(c, N)
+		if c.Sign() == 0 {
+			return nil
+		}
+		a := new(big.Int).Mul(c, big.NewInt(3))
+		b := new(big.Int).Lsh(c, 1)
+		// the quadratic twist by a non-residue g
+		// has the other possible order
+		g := big.NewInt(2)
+		for big.Jacobi(g, N) != -1 {
+			g.Add(g, one)
+		}
+		g2 := new(big.Int).Mul(g, g)
+		g3 := new(big.Int).Mul(g2, g)
+		curves = append(curves,
+			[2]*big.Int{a.Mod(a, N), b.Mod(b, N)},
+			[2]*big.Int{new(big.Int).Mod(g2.Mul(g2, a), N), new(big.Int).Mod(g3.Mul(g3, b), N)})
+	}
+
+	// Step 2: look for a point P with kP != 0 and qkP = 0
+	for _, c := range curves {
+		a, b := c[0], c[1]
+		x := new(big.Int)
+		for tries := 0; tries < 8; tries++ {
+			P := randomPoint(x, a, b, N)
+			if P == nil {
+				return nil
+			}
+			kP, ok := ecMul(P, k, a, N)
+			if !ok {
+				return nil
+			}
+			if kP == nil {
+				continue
+			}
+			if R, ok := ecMul(kP, q, a, N); ok && R == nil {
+				return &ECPPStep{N: new(big.Int).Set(N), A: a, B: b, X: P.X, Y: P.Y, Q: q}
+			}
+			// wrong order, try the next curve
+			break
+		}
+	}
+	return nil
+}
+
+// randomPoint returns the point on y^2 = x^3 + ax + b mod N
+// with the next x coordinate after x that has one.
+func randomPoint(x, a, b, N *big.Int) *ecPoint {
+	rhs := new(big.Int)
+	for i := 0; i < 1000; i++ {
+		x.Add(x, one)
+		rhs.Mul(x, x)
+		rhs.Add(rhs, a)
+		rhs.Mul(rhs, x)
+		rhs.Add(rhs, b)
+		rhs.Mod(rhs, N)
+		if big.Jacobi(rhs, N) != 1 {
+			continue
+		}
+		y := new(big.Int).ModSqrt(rhs, N)
+		if y == nil {
+			return nil
+		}
+		return &ecPoint{new(big.Int).Set(x), y}
+	}
+	return nil
+}
+
+// ecPoint is an affine point on a curve, nil is the point at infinity.
+type ecPoint struct{ X, Y *big.Int }
+
+func onCurve(x, y, a, b, N *big.Int) bool {
+	l := new(big.Int).Mul(y, y)
+	r := new(big.Int).Mul(x, x)
+	r.Add(r, a)
+	r.Mul(r, x)
+	r.Add(r, b)
+	return l.Sub(l, r).Mod(l, N).Sign() == 0
+}
+
+// ecAdd returns P + Q on y^2 = x^3 + ax + b mod N.
+// N need not be prime, if the sum can not be computed
+// consistently mod every prime dividing N it returns false.
+func ecAdd(P, Q *ecPoint, a, N *big.Int) (*ecPoint, bool) {
+	if P == nil {
+		return Q, true
+	}
+	if Q == nil {
+		return P, true
+	}
+	l := new(big.Int)
+	d := new(big.Int)
+	if P.X.Cmp(Q.X) == 0 {
+		if d.Add(P.Y, Q.Y).Mod(d, N).Sign() == 0 {
+			// Q = -P
+			return nil, true
+		}
+		if P.Y.Cmp(Q.Y) != 0 {
+			return nil, false
+		}
+		// doubling: l = (3x^2 + a) / 2y
+		l.Mul(P.X, P.X)
+		l.Mul(l, big.NewInt(3))
+		l.Add(l, a)
+		d.Lsh(P.Y, 1)
+	} else {
+		// adding: l = (y2 - y1) / (x2 - x1)
+		l.Sub(Q.Y, P.Y)
+		d.Sub(Q.X, P.X)
+	}
+	if d.ModInverse(d.Mod(d, N), N) == nil {
+		return nil, false
+	}
+	l.Mul(l, d)
+	l.Mod(l, N)
+	x := new(big.Int).Mul(l, l)
+	x.Sub(x, P.X)
+	x.Sub(x, Q.X)
+	x.Mod(x, N)
+	y := new(big.Int).Sub(P.X, x)
+	y.Mul(y, l)
+	y.Sub(y, P.Y)
+	y.Mod(y, N)
+	return &ecPoint{x, y}, true
+}
+
+// ecMul returns kP using double and add.
+func ecMul(P *ecPoint, k, a, N *big.Int) (R *ecPoint, ok bool) {
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		if R, ok = ecAdd(R, R, a, N); !ok {
+			return nil, false
+		}
+		if k.Bit(i) == 1 {
+			if R, ok = ecAdd(R, P, a, N); !ok {
+				return nil, false
+			}
+		}
+	}
+	return R, true
+}
@@ -0,0 +1,106 @@
+package prime
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHilbertClassPolynomial(t *testing.T) {
+	cases := []struct {
+		D    int
+		want []int64
+	}{
+		{-3, []int64{0, 1}},
+		{-4, []int64{-1728, 1}},
+		{-7, []int64{3375, 1}},
+		{-8, []int64{-8000, 1}},
+		{-163, []int64{262537412640768000, 1}},
+		{-15, []int64{-121287375, 191025, 1}},
+		{-23, []int64{12771880859375, -5151296875, 3491750, 1}},
+	}
+	for _, c := range cases {
+		H := hilbertClassPolynomial(c.D)
+		require.Len(t, H, len(c.want), fmt.Sprintf("D=%d", c.D))
+		for i, x := range c.want {
+			assert.Equal(t, big.NewInt(x), H[i], fmt.Sprintf("D=%d, i=%d", c.D, i))
+		}
+	}
+}
+
+func TestCornacchia(t *testing.T) {
+	for _, D := range []int{-3, -4, -7, -8, -11, -15, -23} {
+		for _, n := range []int64{1009, 1013, 170003, 1700021} {
+			N := big.NewInt(n)
+			u, v := cornacchia(D, N)
+			if u == nil {
+				continue
+			}
+			// 4N = u^2 + |D| v^2
+			x := new(big.Int).Mul(u, u)
+			x.Add(x, new(big.Int).Mul(big.NewInt(int64(-D)), new(big.Int).Mul(v, v)))
+			assert.Equal(t, new(big.Int).Lsh(N, 2), x, fmt.Sprintf("D=%d, N=%d", D, n))
+		}
+	}
+}
+
+func TestECPPCertificate(t *testing.T) {
+	cases := []struct {
+		in   *big.Int
+		want bool
+	}{
+		{big.NewInt(1), false},
+		{big.NewInt(2), true},
+		{big.NewInt(1709), true},
+		{big.NewInt(2047), false},
+		{big.NewInt(2305843009213693951), true}, // 2^61 - 1
+		{new(big.Int).Mul(big.NewInt(2305843009213693951), big.NewInt(2147483647)), false},
+		{new(big.Int).Sub(new(big.Int).Lsh(one, 127), one), true},
+	}
+	for _, c := range cases {
+		cert := ECPPCertificate(c.in)
+		assert.Equal(t, c.want, cert != nil, fmt.Sprintf("in=%d", c.in))
+		if cert != nil {
+			assert.True(t, VerifyECPP(cert), fmt.Sprintf("in=%d", c.in))
+		}
+	}
+	for _, bits := range []int{64, 128, 256, 512} {
+		p := RandPrime(bits)
+		require.True(t, VerifyECPP(ECPPCertificate(p)), fmt.Sprintf("p=%d", p))
+	}
+}
+
+func TestECPPCertificateLarge(t *testing.T) {
+	if testing.Short() {
+		t.Skip("about 10s for 1024 bits")
+	}
+	p := RandPrime(1024)
+	cert := ECPPCertificate(p)
+	require.NotNil(t, cert, fmt.Sprintf("p=%d", p))
+	assert.True(t, VerifyECPP(cert), fmt.Sprintf("p=%d", p))
+	assert.False(t, VerifyECPP(&ECPP{N: new(big.Int).Add(p, two), Steps: cert.Steps}))
+}
+
+func TestVerifyECPP(t *testing.T) {
+	cert := ECPPCertificate(RandPrime(128))
+	require.NotNil(t, cert)
+	require.True(t, VerifyECPP(cert))
+	require.NotEmpty(t, cert.Steps)
+	bad := *cert
+	bad.N = new(big.Int).Add(cert.N, two)
+	assert.False(t, VerifyECPP(&bad))
+	bad = *cert
+	bad.Steps = append([]ECPPStep(nil), cert.Steps...)
+	bad.Steps[0].M = new(big.Int).Add(cert.Steps[0].M, one)
+	assert.False(t, VerifyECPP(&bad))
+	bad.Steps[0] = cert.Steps[0]
+	bad.Steps[0].Y = new(big.Int).Add(cert.Steps[0].Y, one)
+	assert.False(t, VerifyECPP(&bad))
+	// the last Q of a truncated chain is too big to trust
+	bad.Steps = cert.Steps[:len(cert.Steps)-1]
+	assert.False(t, VerifyECPP(&bad))
+	assert.False(t, VerifyECPP(nil))
+}
@@ -0,0 +1,311 @@
+package prime
+
+import (
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// Factor64 returns the prime factors of n in increasing order,
+// each repeated as often as it divides n, or nil for n < 2.
+// It works only with uint64s: after trial division by the
+// primes below 2^10 each cofactor below 2^30 is split with
+// Hart's one line factoring or else Lehman's method, and the
+// larger ones with Pollard rho in Montgomery form or, in the
+// rare case that fails, SQUFOF, until isPrime64 says it is
+// prime. Rho's n^(1/4) steps are each a few multiplications,
+// so above 30 bits it is faster than Lehman's n^(1/3) steps
+// and SQUFOF's n^(1/4) steps which each need a division.
+//
+// See Hart, "A one line factoring algorithm" (2012),
+// and Gower and Wagstaff, "Square form factorization" (2008).
+func Factor64(n uint64) []uint64 {
+	// Step 1: trial divide by the primes below 2^10
+	if n < 2 {
+		return nil
+	}
+	var F []uint64
+	for i := bits.TrailingZeros64(n); i > 0; i-- {
+		F = append(F, 2)
+	}
+	n >>= bits.TrailingZeros64(n)
+	for _, p := range primes10[1:] {
+		p := uint64(p)
+		if p*p > n {
+			break
+		}
+		for n%p == 0 {
+			F = append(F, p)
+			n /= p
+		}
+	}
+	// anything left below 2^20 has no factor below its square root
+	if n < 1<<20 {
+		if n > 1 {
+			F = append(F, n)
+		}
+		return F
+	}
+
+	// Step 2: split the cofactors until they are all prime
+	stack := []uint64{n}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n == 1 {
+			continue
+		}
+		if isPrime64(n) {
+			F = append(F, n)
+			continue
+		}
+		var d uint64
+		if n < 1<<30 {
+			if d = hart64(n, 1<<10); d == 0 {
+				d = lehman64(n)
+			}
+		} else if d = rho64(n, 1); d == 0 && n < 1<<62 {
+			d = squfof64(n)
+		}
+		for c := uint64(2); d == 0; c++ {
+			d = rho64(n, c)
+		}
+		stack = append(stack, d, n/d)
+	}
+	slices.Sort(F)
+	return F
+}
+
+// hart64 looks for a factor of n with Hart's one line
+// factoring: when s = ceil(sqrt(in)) has s^2 mod n = t^2
+// then gcd(s - t, n) divides n. It tries i < limit and
+// returns 0 if no factor was found.
+func hart64(n uint64, limit uint64) uint64 {
+	for i := uint64(1); i < limit; i++ {
+		hi, ni := bits.Mul64(n, i)
+		if hi != 0 || ni > 1<<62 {
+			return 0
+		}
+		s := sqrt64(ni)
+		if s*s != ni {
+			s++
+		}
+		// s^2 - in < 2s + 1 < n
+		m := s*s - ni
+		if t := sqrt64(m); t*t == m {
+			if d := gcd64(s-t, n); d != 1 && d != n {
+				return d
+			}
+		}
+	}
+	return 0
+}
+
+// lehman64 returns a factor of the composite n < 2^42 with
+// Lehman's method, which finds a^2 - 4kn = b^2 with k at most
+// n^(1/3) once n has no prime factors below n^(1/3).
+//
+// See Lehman, "Factoring large integers" (1974).
+func lehman64(n uint64) uint64 {
+	// Step 1: trial divide up to n^(1/3)
+	for _, p := range primes16 {
+		p := uint64(p)
+		if p*p*p > n {
+			break
+		}
+		if n%p == 0 {
+			return p
+		}
+	}
+
+	// Step 2: for k <= n^(1/3) look at
+	// sqrt(4kn) <= a <= sqrt(4kn) + n^(1/6)/(4 sqrt(k))
+	c := math.Cbrt(float64(n))
+	for k := uint64(1); float64(k) <= c+1; k++ {
+		kn := 4 * k * n
+		a := sqrt64(kn)
+		if a*a != kn {
+			a++
+		}
+		end := a + uint64(math.Sqrt(c)/(4*math.Sqrt(float64(k))))
+		for ; a <= end; a++ {
+			m := a*a - kn
+			if b := sqrt64(m); b*b == m {
+				if d := gcd64(a+b, n); d != 1 && d != n {
+					return d
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// squares64 has bit i set when i is a square mod 64.
+var squares64 = func() (m uint64) {
+	for i := uint64(0); i < 64; i++ {
+		m |= 1 << (i * i & 63)
+	}
+	return
+}()
+
+// squfof64 looks for a factor of n < 2^62 with Shanks' square
+// forms factorization on the continued fraction of sqrt(kn)
+// for small square free k, returning 0 if none was found.
+//
+// See Gower and Wagstaff, "Square form factorization" (2008).
+func squfof64(n uint64) uint64 {
+	if s := sqrt64(n); s*s == n {
+		return s
+	}
+	for _, k := range []uint64{1, 3, 5, 7, 11, 3 * 5, 3 * 7, 3 * 11, 5 * 7, 5 * 11, 7 * 11, 3 * 5 * 7, 3 * 5 * 11, 3 * 7 * 11, 5 * 7 * 11, 3 * 5 * 7 * 11} {
+		if n > math.MaxUint64/k {
+			break
+		}
+		// Step 1: look for a square Q_i with i even in the
+		// continued fraction of sqrt(kn), the arithmetic
+		// wrapping around where differences are negative
+		D := k * n
+		P0 := sqrt64(D)
+		P, Pprev, Qprev, Q := P0, P0, uint64(1), D-P0*P0
+		if Q == 0 {
+			continue
+		}
+		B := 6 * uint64(math.Sqrt(2*math.Sqrt(float64(D))))
+		var r uint64
+		i := uint64(2)
+		for ; i < B; i++ {
+			b := (P0 + P) / Q
+			P = b*Q - P
+			q := Q
+			Q = Qprev + b*(Pprev-P)
+			if i%2 == 0 && squares64>>(Q&63)&1 == 1 {
+				if r = sqrt64(Q); r*r == Q {
+					break
+				}
+			}
+			Qprev, Pprev = q, P
+		}
+		if i >= B {
+			continue
+		}
+
+		// Step 2: from the square root of that form go
+		// until P repeats, where Q has a factor of n
+		b := (P0 - P) / r
+		P = b*r + P
+		Pprev = P
+		Qprev = r
+		Q = (D - Pprev*Pprev) / Qprev
+		for i = 0; i < B; i++ {
+			b := (P0 + P) / Q
+			Pprev = P
+			P = b*Q - P
+			q := Q
+			Q = Qprev + b*(Pprev-P)
+			Qprev = q
+			if P == Pprev {
+				break
+			}
+		}
+		if d := gcd64(n, Qprev); d != 1 && d != n {
+			return d
+		}
+	}
+	return 0
+}
+
+// rho64 looks for a factor of the odd n with Brent's
+// version of Pollard rho as in brentRho, but with the
+// map x -> x^2 + c done in Montgomery form. It returns
+// 0 if the cycles mod every prime line up.
+func rho64(n, c uint64) uint64 {
+	const m = 128
+	mont := newMontgomery64(n)
+	c %= n
+	f := func(x uint64) uint64 {
+		// x^2 + c mod n without overflowing
+		x = mont.mul(x, x)
+		if x >= n-c {
+			return x - (n - c)
+		}
+		return x + c
+	}
+	y, x, ys := uint64(2)%n, uint64(0), uint64(0)
+	q, g := mont.one, uint64(1)
+	for r := 1; g == 1; r <<= 1 {
+		x = y
+		for i := 0; i < r; i++ {
+			y = f(y)
+		}
+		for k := 0; k < r && g == 1; k += m {
+			ys = y
+			for i := 0; i < min(m, r-k); i++ {
+				y = f(y)
+				q = mont.mul(q, max(x, y)-min(x, y))
+			}
+			g = gcd64(q, n)
+		}
+	}
+	if g == n {
+		// the batch overshot so redo it one step at a time
+		for g = 1; g == 1; {
+			ys = f(ys)
+			g = gcd64(max(x, ys)-min(x, ys), n)
+		}
+	}
+	if g == n {
+		return 0
+	}
+	return g
+}
+
+// montgomery64 is arithmetic mod an odd n < 2^64 on
+// x R mod n for R = 2^64, where the reduction is a
+// multiplication instead of a division.
+//
+// See Montgomery, "Modular multiplication without
+// trial division" (1985).
+type montgomery64 struct {
+	n, ninv, one uint64
+}
+
+func newMontgomery64(n uint64) montgomery64 {
+	// Newton's method doubles the bits of n^-1 mod 2^64
+	// each time, and n is its own inverse mod 8
+	ninv := n
+	for i := 0; i < 5; i++ {
+		ninv *= 2 - n*ninv
+	}
+	return montgomery64{n: n, ninv: ninv, one: -n % n}
+}
+
+// mul returns a b / R mod n for a, b < n.
+func (m montgomery64) mul(a, b uint64) uint64 {
+	// (ab - un)/R with u = ab n^-1 mod R, which is exact
+	hi, lo := bits.Mul64(a, b)
+	un, _ := bits.Mul64(lo*m.ninv, m.n)
+	if hi < un {
+		return hi - un + m.n
+	}
+	return hi - un
+}
+
+// gcd64 returns gcd(a, b) with the binary algorithm.
+func gcd64(a, b uint64) uint64 {
+	if a == 0 {
+		return b
+	}
+	if b == 0 {
+		return a
+	}
+	k := bits.TrailingZeros64(a | b)
+	a >>= bits.TrailingZeros64(a)
+	for b != 0 {
+		b >>= bits.TrailingZeros64(b)
+		if a > b {
+			a, b = b, a
+		}
+		b -= a
+	}
+	return a << k
+}
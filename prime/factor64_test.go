@@ -0,0 +1,88 @@
+package prime
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFactor64(t *testing.T) {
+	cases := []struct {
+		n uint64
+		F []uint64
+	}{
+		{0, nil},
+		{1, nil},
+		{2, []uint64{2}},
+		{1 << 63, slices.Repeat([]uint64{2}, 63)},
+		{1001, []uint64{7, 11, 13}},
+		{1021 * 1021, []uint64{1021, 1021}},
+		{1031 * 1033, []uint64{1031, 1033}},
+		{65537 * 65537 * 12, []uint64{2, 2, 3, 65537, 65537}},
+		{(1<<20 - 3) * (1<<20 + 7), []uint64{1<<20 - 3, 1<<20 + 7}},
+		{(1<<31 - 1) * (1<<31 + 11), []uint64{1<<31 - 1, 1<<31 + 11}},
+		{4294967291 * 4294967279, []uint64{4294967279, 4294967291}},
+		{1<<64 - 59, []uint64{1<<64 - 59}},
+		{1<<64 - 1, []uint64{3, 5, 17, 257, 641, 65537, 6700417}},
+		{33554467 * 134217757, []uint64{33554467, 134217757}},
+		{1073741827 * 1073741827 * 13, []uint64{13, 1073741827, 1073741827}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.F, Factor64(c.n), fmt.Sprintf("n=%d", c.n))
+	}
+	// random numbers and semiprimes of every size
+	random := rand.New(rand.NewSource(1))
+	for b := 2; b <= 64; b++ {
+		for i := 0; i < 20; i++ {
+			n := random.Uint64() >> (64 - b)
+			if i%2 == 1 {
+				p := nextPrime64(random.Uint64() >> (64 - b/2))
+				q := nextPrime64(random.Uint64() >> (64 - (b+1)/2))
+				n = p * q
+			}
+			F := Factor64(n)
+			m := uint64(1)
+			for _, p := range F {
+				assert.True(t, isPrime64(p), fmt.Sprintf("n=%d, p=%d", n, p))
+				m *= p
+			}
+			if n > 1 {
+				assert.Equal(t, n, m, fmt.Sprintf("n=%d", n))
+			}
+			assert.True(t, slices.IsSorted(F))
+		}
+	}
+}
+
+func TestFactor64Methods(t *testing.T) {
+	for _, c := range [][2]uint64{
+		{1031, 1033},
+		{1<<20 - 3, 1<<20 + 7},
+		{1048583, 2097169},
+		{33554467, 134217757},
+		{2147483647, 2147483659},
+		{4294967279, 4294967291},
+	} {
+		n := c[0] * c[1]
+		check := func(name string, d uint64) {
+			assert.True(t, d == c[0] || d == c[1], fmt.Sprintf("%s: n=%d, d=%d", name, n, d))
+		}
+		if n < 1<<42 {
+			check("lehman", lehman64(n))
+		}
+		if n < 1<<62 {
+			check("squfof", squfof64(n))
+		}
+		check("rho", rho64(n, 1))
+	}
+	assert.Equal(t, uint64(1031), hart64(1031*1033, 1<<16))
+}
+
+func nextPrime64(n uint64) uint64 {
+	for n = max(n, 2); !isPrime64(n); n++ {
+	}
+	return n
+}
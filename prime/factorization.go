@@ -0,0 +1,193 @@
+package prime
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PrimePower is P^E.
+type PrimePower struct {
+	P *big.Int `json:"p"`
+	E uint64   `json:"e"`
+}
+
+// Factorization is a product of prime powers sorted by prime,
+// each prime appearing once with a positive exponent. The empty
+// Factorization is 1. In JSON it is a list of {"p": P, "e": E}.
+type Factorization []PrimePower
+
+// ParseFactorization parses a product of powers such as
+// "2^3 * 5 * 7", as printed by String, or "1". The bases
+// are not checked to be prime.
+func ParseFactorization(s string) (Factorization, error) {
+	if strings.TrimSpace(s) == "1" {
+		return Factorization{}, nil
+	}
+	var F Factorization
+	for _, term := range strings.Split(s, "*") {
+		base, exp, found := strings.Cut(strings.TrimSpace(term), "^")
+		p, ok := new(big.Int).SetString(base, 10)
+		if !ok {
+			return nil, fmt.Errorf("prime: invalid factor %q", term)
+		}
+		e := uint64(1)
+		if found {
+			var err error
+			if e, err = strconv.ParseUint(exp, 10, 64); err != nil {
+				return nil, fmt.Errorf("prime: invalid exponent in %q", term)
+			}
+		}
+		F = append(F, PrimePower{P: p, E: e})
+	}
+	return normalize(F)
+}
+
+// normalize sorts F, adds the exponents of equal primes and
+// drops those with exponent 0. It fails if some P is below 2
+// or the exponents of a prime add up to more than a uint64.
+func normalize(F Factorization) (Factorization, error) {
+	G := make(Factorization, 0, len(F))
+	for _, pe := range F {
+		if pe.P == nil || pe.P.Cmp(two) < 0 {
+			return nil, fmt.Errorf("prime: invalid prime %v in factorization", pe.P)
+		}
+		if !G.add(pe.P, pe.E) {
+			return nil, fmt.Errorf("prime: exponent of %d overflows in factorization", pe.P)
+		}
+	}
+	return G, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler,
+// normalizing the list as in ParseFactorization.
+func (F *Factorization) UnmarshalJSON(b []byte) error {
+	var pes []PrimePower
+	if err := json.Unmarshal(b, &pes); err != nil {
+		return err
+	}
+	G, err := normalize(pes)
+	if err != nil {
+		return err
+	}
+	*F = G
+	return nil
+}
+
+// add multiplies F by p^e, keeping it sorted. It returns
+// false and leaves F alone if the exponent would overflow.
+func (F *Factorization) add(p *big.Int, e uint64) bool {
+	if e == 0 {
+		return true
+	}
+	G := *F
+	i := sort.Search(len(G), func(i int) bool { return G[i].P.Cmp(p) >= 0 })
+	if i < len(G) && G[i].P.Cmp(p) == 0 {
+		sum, carry := bits.Add64(G[i].E, e, 0)
+		if carry != 0 {
+			return false
+		}
+		G[i].E = sum
+		return true
+	}
+	G = append(G, PrimePower{})
+	copy(G[i+1:], G[i:])
+	G[i] = PrimePower{P: new(big.Int).Set(p), E: e}
+	*F = G
+	return true
+}
+
+// merge walks F and G in order and returns the prime powers
+// p^f(e1, e2) with a positive exponent, where a prime missing
+// from F or G has exponent 0 there.
+func merge(F, G Factorization, f func(e1, e2 uint64) uint64) Factorization {
+	H := Factorization{}
+	push := func(p *big.Int, e uint64) {
+		if e > 0 {
+			H = append(H, PrimePower{P: new(big.Int).Set(p), E: e})
+		}
+	}
+	i, j := 0, 0
+	for i < len(F) || j < len(G) {
+		switch {
+		case j == len(G) || (i < len(F) && F[i].P.Cmp(G[j].P) < 0):
+			push(F[i].P, f(F[i].E, 0))
+			i++
+		case i == len(F) || F[i].P.Cmp(G[j].P) > 0:
+			push(G[j].P, f(0, G[j].E))
+			j++
+		default:
+			push(F[i].P, f(F[i].E, G[j].E))
+			i++
+			j++
+		}
+	}
+	return H
+}
+
+// Mul returns F G. It panics if an exponent overflows a uint64.
+func (F Factorization) Mul(G Factorization) Factorization {
+	return merge(F, G, func(e1, e2 uint64) uint64 {
+		e, carry := bits.Add64(e1, e2, 0)
+		if carry != 0 {
+			panic("prime: exponent overflow in Factorization.Mul")
+		}
+		return e
+	})
+}
+
+// LCM returns the least common multiple of F and G.
+func (F Factorization) LCM(G Factorization) Factorization {
+	return merge(F, G, func(e1, e2 uint64) uint64 { return max(e1, e2) })
+}
+
+// GCD returns the greatest common divisor of F and G.
+func (F Factorization) GCD(G Factorization) Factorization {
+	return merge(F, G, func(e1, e2 uint64) uint64 { return min(e1, e2) })
+}
+
+// Pow returns F^k. It panics if an exponent overflows a uint64.
+func (F Factorization) Pow(k uint64) Factorization {
+	return merge(F, nil, func(e, _ uint64) uint64 {
+		hi, lo := bits.Mul64(k, e)
+		if hi != 0 {
+			panic("prime: exponent overflow in Factorization.Pow")
+		}
+		return lo
+	})
+}
+
+// Divides reports whether F divides G.
+func (F Factorization) Divides(G Factorization) bool {
+	// the primes with a larger exponent in F than in G
+	return len(merge(F, G, func(e1, e2 uint64) uint64 { return e1 - min(e1, e2) })) == 0
+}
+
+// Value returns the product of the prime powers.
+func (F Factorization) Value() *big.Int {
+	N := big.NewInt(1)
+	z := new(big.Int)
+	for _, pe := range F {
+		N.Mul(N, z.Exp(pe.P, new(big.Int).SetUint64(pe.E), nil))
+	}
+	return N
+}
+
+// String returns F as in "2^3 * 5 * 7", or "1" if it is empty.
+func (F Factorization) String() string {
+	if len(F) == 0 {
+		return "1"
+	}
+	s := make([]string, len(F))
+	for i, pe := range F {
+		s[i] = pe.P.String()
+		if pe.E != 1 {
+			s[i] += "^" + strconv.FormatUint(pe.E, 10)
+		}
+	}
+	return strings.Join(s, " * ")
+}
@@ -0,0 +1,90 @@
+package prime
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFactorization(t *testing.T) {
+	cases := []struct {
+		s, want string
+	}{
+		{"1", "1"},
+		{" 1 ", "1"},
+		{"2^3 * 5 * 7", "2^3 * 5 * 7"},
+		{"7*5*2^3", "2^3 * 5 * 7"},
+		{"3 * 2 * 3^2", "2 * 3^3"},
+		{"5^0 * 2", "2"},
+		{"340282366920938463463374607431768211507^2", "340282366920938463463374607431768211507^2"},
+	}
+	for _, c := range cases {
+		F, err := ParseFactorization(c.s)
+		require.NoError(t, err, c.s)
+		assert.Equal(t, c.want, F.String(), c.s)
+	}
+	for _, s := range []string{"", "x", "2^", "2^-1", "1 * 2", "0", "-3", "2 ** 3", "2^3^2", "2^18446744073709551615 * 2"} {
+		_, err := ParseFactorization(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestFactorizationArithmetic(t *testing.T) {
+	parse := func(s string) Factorization {
+		F, err := ParseFactorization(s)
+		require.NoError(t, err, s)
+		return F
+	}
+	F := parse("2^3 * 5 * 7")
+	G := parse("2 * 3^2 * 7^4")
+	assert.Equal(t, "2^4 * 3^2 * 5 * 7^5", F.Mul(G).String())
+	assert.Equal(t, "2^3 * 3^2 * 5 * 7^4", F.LCM(G).String())
+	assert.Equal(t, "2 * 7", F.GCD(G).String())
+	assert.Equal(t, "1", F.GCD(parse("3 * 11")).String())
+	assert.Equal(t, "2^6 * 5^2 * 7^2", F.Pow(2).String())
+	assert.Equal(t, "1", F.Pow(0).String())
+	assert.True(t, parse("2 * 7").Divides(F))
+	assert.True(t, parse("1").Divides(F))
+	assert.True(t, F.Divides(F))
+	assert.False(t, F.Divides(G))
+	assert.False(t, parse("2^4").Divides(F))
+	// exponents which do not fit in a uint64
+	huge := Factorization{{P: big.NewInt(2), E: 1 << 63}}
+	assert.Panics(t, func() { huge.Pow(2) })
+	assert.Panics(t, func() { huge.Mul(huge) })
+	assert.Equal(t, uint64(1<<64-1), huge.Mul(Factorization{{P: big.NewInt(2), E: 1<<63 - 1}})[0].E)
+	assert.Equal(t, uint64(1<<63), parse("2").Pow(1 << 63)[0].E)
+	assert.Equal(t, big.NewInt(280), F.Value())
+	assert.Equal(t, big.NewInt(1), Factorization{}.Value())
+	// the results do not share primes with F or G
+	H := F.Mul(G)
+	H[0].P.SetInt64(4)
+	assert.Equal(t, "2^3 * 5 * 7", F.String())
+	assert.Equal(t, "2 * 3^2 * 7^4", G.String())
+	// a value can be factored back
+	N := F.Mul(G).Value()
+	assert.Equal(t, F.Mul(G), Factor(N))
+	assert.Equal(t, Factor(big.NewInt(360)).LCM(Factor(big.NewInt(84))), Factor(big.NewInt(2520)))
+}
+
+func TestFactorizationJSON(t *testing.T) {
+	F := Factor(big.NewInt(2 * 2 * 2 * 5 * 7))
+	b, err := json.Marshal(F)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"p":2,"e":3},{"p":5,"e":1},{"p":7,"e":1}]`, string(b))
+	var G Factorization
+	require.NoError(t, json.Unmarshal(b, &G))
+	assert.Equal(t, F, G)
+	// normalized on the way in
+	require.NoError(t, json.Unmarshal([]byte(`[{"p":7,"e":1},{"p":2,"e":1},{"p":2,"e":2},{"p":5,"e":1},{"p":3,"e":0}]`), &G))
+	assert.Equal(t, F, G)
+	assert.Error(t, json.Unmarshal([]byte(`[{"p":1,"e":1}]`), &G))
+	assert.Error(t, json.Unmarshal([]byte(`[{"e":1}]`), &G))
+	assert.Error(t, json.Unmarshal([]byte(`{"p":2}`), &G))
+	b, err = json.Marshal(Factor(one))
+	require.NoError(t, err)
+	assert.Equal(t, `[]`, string(b))
+}
@@ -0,0 +1,485 @@
+package prime
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+// limits on the discriminants used to build CM curves
+const (
+	maxDiscriminant = 40000
+	maxClassNumber  = 40
+)
+
+var (
+	discriminantsOnce sync.Once
+	discriminants     []int
+
+	hilbertMu    sync.Mutex
+	hilbertCache = map[int][]*big.Int{}
+)
+
+// quadForm is the binary quadratic form ax^2 + bxy + cy^2.
+type quadForm struct{ a, b, c int }
+
+// cmDiscriminants returns the fundamental discriminants
+// D < 0 with |D| <= maxDiscriminant and class number
+// at most maxClassNumber, sorted by class number.
+func cmDiscriminants() []int {
+	discriminantsOnce.Do(func() {
+		h := make(map[int]int)
+		for D := -3; D >= -maxDiscriminant; D-- {
+			if !isFundamental(D) {
+				continue
+			}
+			if n := len(reducedForms(D)); n <= maxClassNumber {
+				h[D] = n
+				discriminants = append(discriminants, D)
+			}
+		}
+		sort.SliceStable(discriminants, func(i, j int) bool {
+			return h[discriminants[i]] < h[discriminants[j]]
+		})
+	})
+	return discriminants
+}
+
+// isFundamental returns true if D < 0 is a
+// fundamental discriminant.
+func isFundamental(D int) bool {
+	switch {
+	case D >= 0:
+		return false
+	case D&3 == 1:
+		return squarefree(-D)
+	case D&3 == 0:
+		m := D / 4
+		return (m&3 == 2 || m&3 == 3) && squarefree(-m)
+	}
+	return false
+}
+
+func squarefree(n int) bool {
+	for p := 2; p*p <= n; p++ {
+		if n%(p*p) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// reducedForms returns the reduced primitive forms
+// of discriminant D < 0. There are h(D) of them.
+func reducedForms(D int) (forms []quadForm) {
+	for a := 1; 3*a*a <= -D; a++ {
+		for b := -a + 1; b <= a; b++ {
+			if (b-D)&1 != 0 {
+				continue
+			}
+			if (b*b-D)%(4*a) != 0 {
+				continue
+			}
+			c := (b*b - D) / (4 * a)
+			if c < a || (c == a && b < 0) {
+				continue
+			}
+			if gcdInt(gcdInt(a, b), c) != 1 {
+				continue
+			}
+			forms = append(forms, quadForm{a, b, c})
+		}
+	}
+	return
+}
+
+func gcdInt(a, b int) int {
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// hilbertClassPolynomial returns the coefficients, lowest
+// degree first, of the Hilbert class polynomial H_D(X).
+// Its roots are the j-invariants of the curves with
+// complex multiplication by the order of discriminant D.
+// See Cohen's book, Ch. 7, algorithm 7.6.1.
+func hilbertClassPolynomial(D int) []*big.Int {
+	hilbertMu.Lock()
+	defer hilbertMu.Unlock()
+	if H, ok := hilbertCache[D]; ok {
+		return H
+	}
+	forms := reducedForms(D)
+	// the largest coefficient is about
+	// exp(pi sqrt|D| sum 1/a) so that many bits
+	// and some padding are needed
+	bits := 0.0
+	for _, f := range forms {
+		bits += math.Pi * math.Sqrt(float64(-D)) / float64(f.a) / math.Ln2
+	}
+	prec := uint(bits) + 16*uint(len(forms)) + 128
+	for {
+		if H := hilbertAtPrecision(D, forms, prec); H != nil {
+			hilbertCache[D] = H
+			return H
+		}
+		prec *= 2
+	}
+}
+
+// computes the product of X - j(tau) over all forms
+// and rounds it, returns nil if precision is too low.
+func hilbertAtPrecision(D int, forms []quadForm, prec uint) []*big.Int {
+	pi := bigPi(prec)
+	poly := []bigComplex{newComplex(1, prec)}
+	for _, f := range forms {
+		j := jInvariant(D, f, pi, prec)
+		// multiply poly by X - j
+		next := make([]bigComplex, len(poly)+1)
+		for i := range next {
+			next[i] = newComplex(0, prec)
+		}
+		for i, c := range poly {
+			next[i+1] = next[i+1].add(c)
+			next[i] = next[i].sub(c.mul(j))
+		}
+		poly = next
+	}
+	H := make([]*big.Int, len(poly))
+	half := big.NewFloat(0.5)
+	tol := big.NewFloat(0.125)
+	for i, c := range poly {
+		if new(big.Float).Abs(c.im).Cmp(tol) > 0 {
+			return nil
+		}
+		x := new(big.Float).SetPrec(prec)
+		if c.re.Sign() < 0 {
+			x.Sub(c.re, half)
+		} else {
+			x.Add(c.re, half)
+		}
+		H[i], _ = x.Int(nil)
+		x.Sub(c.re, x.SetInt(H[i]))
+		if x.Abs(x).Cmp(tol) > 0 {
+			return nil
+		}
+	}
+	return H
+}
+
+// jInvariant returns j(tau) for tau = (-b + sqrt(D))/2a.
+// It uses j = (256f + 1)^3 / f with f = Delta(2 tau)/Delta(tau)
+// computed from Euler's pentagonal number theorem.
+func jInvariant(D int, f quadForm, pi *big.Float, prec uint) bigComplex {
+	// q = exp(2 pi i tau) = exp(-pi sqrt|D|/a) exp(-i pi b/a)
+	r := new(big.Float).SetPrec(prec).SetInt64(int64(-D))
+	r.Sqrt(r)
+	r.Mul(r, pi)
+	r.Quo(r, new(big.Float).SetPrec(prec).SetInt64(int64(f.a)))
+	r.Neg(r)
+	theta := new(big.Float).SetPrec(prec).SetInt64(int64(-f.b))
+	theta.Mul(theta, pi)
+	theta.Quo(theta, new(big.Float).SetPrec(prec).SetInt64(int64(f.a)))
+	mod := bigExp(r)
+	cos, sin := bigCosSin(theta)
+	q := bigComplex{cos.Mul(cos, mod), sin.Mul(sin, mod)}
+	logq := -math.Pi * math.Sqrt(float64(-D)) / float64(f.a) / math.Ln2
+
+	t := eulerProduct(q.mul(q), 2*logq, prec).quo(eulerProduct(q, logq, prec))
+	t = t.mul(t).mul(t)
+	for i := 0; i < 3; i++ {
+		t = t.mul(t)
+	}
+	F := q.mul(t) // now f = q (prod (1-q^2n) / prod (1-q^n))^24
+	num := F.scale(256).add(newComplex(1, prec))
+	return num.mul(num).mul(num).quo(F)
+}
+
+// eulerProduct returns prod (1-q^n) for n >= 1
+// where logq = log_2 |q| is used to stop the sum.
+func eulerProduct(q bigComplex, logq float64, prec uint) bigComplex {
+	sum := newComplex(1, prec)
+	for k := int64(1); ; k++ {
+		e1 := k * (3*k - 1) / 2
+		if float64(e1)*logq < -float64(prec) {
+			return sum
+		}
+		term := q.pow(e1).add(q.pow(e1 + k))
+		if k&1 == 1 {
+			sum = sum.sub(term)
+		} else {
+			sum = sum.add(term)
+		}
+	}
+}
+
+// bigComplex is a complex number with big.Float parts.
+type bigComplex struct{ re, im *big.Float }
+
+func newComplex(x int64, prec uint) bigComplex {
+	return bigComplex{
+		new(big.Float).SetPrec(prec).SetInt64(x),
+		new(big.Float).SetPrec(prec),
+	}
+}
+
+func (z bigComplex) prec() uint { return z.re.Prec() }
+
+func (z bigComplex) add(w bigComplex) bigComplex {
+	p := z.prec()
+	return bigComplex{
+		new(big.Float).SetPrec(p).Add(z.re, w.re),
+		new(big.Float).SetPrec(p).Add(z.im, w.im),
+	}
+}
+
+func (z bigComplex) sub(w bigComplex) bigComplex {
+	p := z.prec()
+	return bigComplex{
+		new(big.Float).SetPrec(p).Sub(z.re, w.re),
+		new(big.Float).SetPrec(p).Sub(z.im, w.im),
+	}
+}
+
+func (z bigComplex) mul(w bigComplex) bigComplex {
+	p := z.prec()
+	a := new(big.Float).SetPrec(p).Mul(z.re, w.re)
+	b := new(big.Float).SetPrec(p).Mul(z.im, w.im)
+	c := new(big.Float).SetPrec(p).Mul(z.re, w.im)
+	d := new(big.Float).SetPrec(p).Mul(z.im, w.re)
+	return bigComplex{a.Sub(a, b), c.Add(c, d)}
+}
+
+func (z bigComplex) quo(w bigComplex) bigComplex {
+	p := z.prec()
+	n := new(big.Float).SetPrec(p).Mul(w.re, w.re)
+	n.Add(n, new(big.Float).SetPrec(p).Mul(w.im, w.im))
+	c := z.mul(bigComplex{w.re, new(big.Float).SetPrec(p).Neg(w.im)})
+	c.re.Quo(c.re, n)
+	c.im.Quo(c.im, n)
+	return c
+}
+
+func (z bigComplex) scale(x int64) bigComplex {
+	p := z.prec()
+	s := new(big.Float).SetPrec(p).SetInt64(x)
+	return bigComplex{
+		new(big.Float).SetPrec(p).Mul(z.re, s),
+		new(big.Float).SetPrec(p).Mul(z.im, s),
+	}
+}
+
+func (z bigComplex) pow(e int64) bigComplex {
+	r := newComplex(1, z.prec())
+	for b := z; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			r = r.mul(b)
+		}
+		b = b.mul(b)
+	}
+	return r
+}
+
+// bigPi returns pi using Machin's formula
+// pi = 16 arctan(1/5) - 4 arctan(1/239).
+func bigPi(prec uint) *big.Float {
+	p := prec + 32
+	arctanInv := func(n int64) *big.Float {
+		x := new(big.Float).SetPrec(p).Quo(big.NewFloat(1), new(big.Float).SetInt64(n))
+		x2 := new(big.Float).SetPrec(p).Mul(x, x)
+		sum := new(big.Float).SetPrec(p).Set(x)
+		term := new(big.Float).SetPrec(p)
+		for k := int64(1); ; k++ {
+			x.Mul(x, x2)
+			term.Quo(x, new(big.Float).SetInt64(2*k+1))
+			if term.Sign() == 0 || term.MantExp(nil) < -int(p) {
+				return sum
+			}
+			if k&1 == 1 {
+				sum.Sub(sum, term)
+			} else {
+				sum.Add(sum, term)
+			}
+		}
+	}
+	pi := new(big.Float).SetPrec(p).Mul(big.NewFloat(16), arctanInv(5))
+	pi.Sub(pi, new(big.Float).SetPrec(p).Mul(big.NewFloat(4), arctanInv(239)))
+	return pi.SetPrec(prec)
+}
+
+// bigExp returns e^x, it halves x until it is small,
+// sums the taylor series and squares back up.
+func bigExp(x *big.Float) *big.Float {
+	prec := x.Prec()
+	k := 0
+	if e := x.MantExp(nil); e > -8 {
+		k = e + 8
+	}
+	p := prec + uint(k) + 32
+	r := new(big.Float).SetPrec(p).SetMantExp(x, -k)
+	sum := new(big.Float).SetPrec(p).SetInt64(1)
+	term := new(big.Float).SetPrec(p).SetInt64(1)
+	for n := int64(1); ; n++ {
+		term.Mul(term, r)
+		term.Quo(term, new(big.Float).SetInt64(n))
+		if term.Sign() == 0 || term.MantExp(nil) < -int(p) {
+			break
+		}
+		sum.Add(sum, term)
+	}
+	for ; k > 0; k-- {
+		sum.Mul(sum, sum)
+	}
+	return sum.SetPrec(prec)
+}
+
+// bigCosSin returns cos(x) and sin(x) for |x| <= pi
+// by summing their taylor series.
+func bigCosSin(x *big.Float) (cos, sin *big.Float) {
+	prec := x.Prec()
+	p := prec + 32
+	cos = new(big.Float).SetPrec(p).SetInt64(1)
+	sin = new(big.Float).SetPrec(p).Set(x)
+	x2 := new(big.Float).SetPrec(p).Mul(x, x)
+	c := new(big.Float).SetPrec(p).SetInt64(1)
+	s := new(big.Float).SetPrec(p).Set(x)
+	for n := int64(1); ; n++ {
+		c.Mul(c, x2)
+		c.Quo(c, new(big.Float).SetInt64((2*n-1)*(2*n)))
+		c.Neg(c)
+		s.Mul(s, x2)
+		s.Quo(s, new(big.Float).SetInt64((2*n)*(2*n+1)))
+		s.Neg(s)
+		cos.Add(cos, c)
+		sin.Add(sin, s)
+		if c.Sign() == 0 || c.MantExp(nil) < -int(p) {
+			return cos.SetPrec(prec), sin.SetPrec(prec)
+		}
+	}
+}
+
+// rootModN returns a root of the polynomial f mod N,
+// f given lowest degree first, using Cantor-Zassenhaus.
+// It assumes f splits into linear factors mod N and
+// returns nil if none is found.
+func rootModN(f []*big.Int, N *big.Int) *big.Int {
+	g := polyTrim(polyMod(f, N))
+	e := new(big.Int).Rsh(new(big.Int).Sub(N, one), 1)
+	delta := new(big.Int)
+	for tries := 0; tries < 128 && len(g) > 1; tries++ {
+		if len(g) == 2 {
+			// g = g1 X + g0 so X = -g0/g1
+			inv := new(big.Int).ModInverse(g[1], N)
+			if inv == nil {
+				return nil
+			}
+			r := new(big.Int).Mul(g[0], inv)
+			r.Neg(r)
+			return r.Mod(r, N)
+		}
+		// gcd(g, (X + delta)^((N-1)/2) - 1) splits off
+		// the roots r where r + delta is a square
+		delta.Add(delta, one)
+		h := polyPowMod([]*big.Int{new(big.Int).Set(delta), big.NewInt(1)}, e, g, N)
+		if len(h) == 0 {
+			h = []*big.Int{new(big.Int)}
+		}
+		h[0].Sub(h[0], one)
+		h[0].Mod(h[0], N)
+		d := polyGCD(g, polyTrim(h), N)
+		if d == nil {
+			return nil
+		}
+		if 1 < len(d) && len(d) < len(g) {
+			g = d
+		}
+	}
+	return nil
+}
+
+func polyMod(f []*big.Int, N *big.Int) []*big.Int {
+	g := make([]*big.Int, len(f))
+	for i, c := range f {
+		g[i] = new(big.Int).Mod(c, N)
+	}
+	return g
+}
+
+func polyTrim(f []*big.Int) []*big.Int {
+	for len(f) > 0 && f[len(f)-1].Sign() == 0 {
+		f = f[:len(f)-1]
+	}
+	return f
+}
+
+// polyRem returns f mod g with coefficients mod N
+// or nil if the leading coefficient of g is not invertible.
+func polyRem(f, g []*big.Int, N *big.Int) []*big.Int {
+	inv := new(big.Int).ModInverse(g[len(g)-1], N)
+	if inv == nil {
+		return nil
+	}
+	r := polyMod(f, N)
+	z := new(big.Int)
+	for len(r) >= len(g) {
+		c := new(big.Int).Mul(r[len(r)-1], inv)
+		c.Mod(c, N)
+		shift := len(r) - len(g)
+		for i, gi := range g {
+			r[shift+i].Sub(r[shift+i], z.Mul(c, gi))
+			r[shift+i].Mod(r[shift+i], N)
+		}
+		r = polyTrim(r[:len(r)-1])
+	}
+	return polyTrim(r)
+}
+
+func polyMulMod(f, g, m []*big.Int, N *big.Int) []*big.Int {
+	if len(f) == 0 || len(g) == 0 {
+		return nil
+	}
+	p := make([]*big.Int, len(f)+len(g)-1)
+	for i := range p {
+		p[i] = new(big.Int)
+	}
+	z := new(big.Int)
+	for i, a := range f {
+		for j, b := range g {
+			p[i+j].Add(p[i+j], z.Mul(a, b))
+		}
+	}
+	return polyRem(p, m, N)
+}
+
+func polyPowMod(f []*big.Int, e *big.Int, m []*big.Int, N *big.Int) []*big.Int {
+	r := []*big.Int{big.NewInt(1)}
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		r = polyMulMod(r, r, m, N)
+		if e.Bit(i) == 1 {
+			r = polyMulMod(r, f, m, N)
+		}
+	}
+	return r
+}
+
+// polyGCD returns the gcd of f and g mod N
+// or nil if some leading coefficient was not invertible.
+func polyGCD(f, g []*big.Int, N *big.Int) []*big.Int {
+	for len(g) > 0 {
+		r := polyRem(f, g, N)
+		if r == nil {
+			return nil
+		}
+		f, g = g, r
+	}
+	return f
+}
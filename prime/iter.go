@@ -0,0 +1,64 @@
+package prime
+
+import (
+	"context"
+	"iter"
+	"math"
+	"math/big"
+)
+
+// All returns the primes p >= start in increasing order.
+// Below 2^64 they come from Sieve and above that
+// they are probable primes as in NextPrime.
+func All(start *big.Int) iter.Seq[*big.Int] {
+	return Between(start, nil)
+}
+
+// Between returns the primes p with lo <= p < hi in increasing
+// order, see All. A nil hi means there is no upper bound.
+func Between(lo, hi *big.Int) iter.Seq[*big.Int] {
+	return func(yield func(*big.Int) bool) {
+		// Step 1: primes below 2^64
+		start := lo
+		if start.Sign() < 0 {
+			start = zero
+		}
+		if start.IsUint64() {
+			end := uint64(math.MaxUint64)
+			if hi != nil && hi.IsUint64() {
+				end = hi.Uint64()
+			} else if hi != nil && hi.Sign() <= 0 {
+				return
+			}
+			more := true
+			Sieve(start.Uint64(), end, func(p uint64) bool {
+				more = yield(new(big.Int).SetUint64(p))
+				return more
+			})
+			if !more || end != math.MaxUint64 {
+				return
+			}
+			// the largest prime below 2^64 is 2^64 - 59
+			start = new(big.Int).Lsh(one, 64)
+		}
+
+		// Step 2: probable primes from the sieve windows, keeping
+		// their state instead of starting over for each prime
+		sieveWindows(context.Background(), start, hi, false, nil, func(p *big.Int) bool {
+			return yield(new(big.Int).Set(p))
+		})
+	}
+}
+
+// All64 is All for primes below 2^64.
+func All64(start uint64) iter.Seq[uint64] {
+	return Between64(start, math.MaxUint64)
+}
+
+// Between64 returns the primes p with
+// lo <= p < hi in increasing order.
+func Between64(lo, hi uint64) iter.Seq[uint64] {
+	return func(yield func(uint64) bool) {
+		Sieve(lo, hi, yield)
+	}
+}
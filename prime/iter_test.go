@@ -0,0 +1,82 @@
+package prime
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAll(t *testing.T) {
+	var got []int64
+	for p := range All(big.NewInt(-5)) {
+		if p.Int64() > 30 {
+			break
+		}
+		got = append(got, p.Int64())
+	}
+	assert.Equal(t, []int64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29}, got)
+
+	// crossing 2^64 where the sieve stops
+	start := new(big.Int).SetUint64(math.MaxUint64 - 100)
+	var ps []*big.Int
+	for p := range All(start) {
+		ps = append(ps, p)
+		if len(ps) == 4 {
+			break
+		}
+	}
+	want := []*big.Int{
+		new(big.Int).SetUint64(math.MaxUint64 - 94),
+		new(big.Int).SetUint64(math.MaxUint64 - 82),
+		new(big.Int).SetUint64(math.MaxUint64 - 58),
+		NextPrime(new(big.Int).Lsh(one, 64)),
+	}
+	assert.Equal(t, want, ps)
+
+	// matches NextPrime with new values each time
+	p := new(big.Int).Lsh(one, 100)
+	ps = nil
+	for q := range All(p) {
+		p = NextPrime(p)
+		assert.Equal(t, p, q)
+		p = new(big.Int).Add(p, one)
+		if ps = append(ps, q); len(ps) == 20 {
+			break
+		}
+	}
+	for i := 1; i < len(ps); i++ {
+		assert.Equal(t, -1, ps[i-1].Cmp(ps[i]))
+	}
+}
+
+func TestBetween(t *testing.T) {
+	count := func(seq func(func(*big.Int) bool)) (n int) {
+		for range seq {
+			n++
+		}
+		return
+	}
+	assert.Equal(t, 25, count(Between(big.NewInt(0), big.NewInt(100))))
+	assert.Equal(t, 0, count(Between(big.NewInt(100), big.NewInt(-100))))
+	assert.Equal(t, 6, count(Between(big.NewInt(1000000), big.NewInt(1000100))))
+	// the same sequence can be ranged over twice
+	lo := new(big.Int).SetUint64(math.MaxUint64 - 100)
+	seq := Between(lo, new(big.Int).Add(new(big.Int).Lsh(one, 64), big.NewInt(100)))
+	assert.Equal(t, count(seq), count(seq))
+	assert.Equal(t, new(big.Int).SetUint64(math.MaxUint64-100), lo)
+}
+
+func TestBetween64(t *testing.T) {
+	var got []uint64
+	for p := range Between64(90, 110) {
+		got = append(got, p)
+	}
+	assert.Equal(t, []uint64{97, 101, 103, 107, 109}, got)
+	got = got[:0]
+	for p := range All64(math.MaxUint64 - 100) {
+		got = append(got, p)
+	}
+	assert.Equal(t, []uint64{math.MaxUint64 - 94, math.MaxUint64 - 82, math.MaxUint64 - 58}, got)
+}
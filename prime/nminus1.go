@@ -0,0 +1,246 @@
+package prime
+
+import (
+	"math/big"
+)
+
+// how many iterations of rho to spend on each composite
+// cofactor when partially factoring N-1
+const partialRhoLimit = 1 << 16
+
+// NMinus1 is an N-1 primality certificate for N.
+// The factors multiply to F which divides N-1 and
+// gcd(F, (N-1)/F) = 1. Each factor Q has a witness A with
+// A^(N-1) = 1 and gcd(A^((N-1)/Q) - 1, N) = 1 mod N.
+// If F >= N^(1/2) this is Pocklington's theorem, if only
+// F >= N^(1/3) the Brillhart-Lehmer-Selfridge test is needed.
+//
+// See Crandall and Pomerance, "Prime Numbers", Section 4.1.
+type NMinus1 struct {
+	N       *big.Int
+	Factors []NMinus1Factor
+}
+
+// NMinus1Factor is a prime power Q^E exactly dividing
+// N-1 with a witness A. If Q has more than 32 bits then
+// Cert is a certificate that Q is prime.
+type NMinus1Factor struct {
+	Q    *big.Int
+	E    uint64
+	A    *big.Int
+	Cert *NMinus1
+}
+
+// ProveNMinus1 returns an N-1 certificate for N or nil if
+// N is composite or not enough of N-1 could be factored.
+// N-1 is factored with trial division and Pollard rho, large
+// prime factors are proven with ProveNMinus1 recursively.
+func ProveNMinus1(N *big.Int) *NMinus1 {
+	// Step 0: parse input / easy cases
+	if N.Cmp(two) < 0 || BPSW(N) == IsComposite {
+		return nil
+	}
+	c := &NMinus1{N: new(big.Int).Set(N)}
+	if N.Cmp(two) == 0 {
+		return c
+	}
+
+	// Step 1: factor N-1 and find witnesses
+	var F *big.Int
+	if c.Factors, F = nMinus1Factors(N); F == nil {
+		return nil
+	}
+
+	// Step 2: make sure F is big enough
+	if !nMinus1Bound(N, F) {
+		return nil
+	}
+	return c
+}
+
+// nMinus1Factors factors as much of N-1 as possible, proves
+// the prime factors are prime and finds witnesses for them.
+// It returns the factors and their product F, or nil F if
+// N was found to be composite.
+func nMinus1Factors(N *big.Int) (fs []NMinus1Factor, F *big.Int) {
+	F = big.NewInt(1)
+	qe := new(big.Int)
+	Q, E := primePowers(new(big.Int).Sub(N, one))
+	for i, q := range Q {
+		var cert *NMinus1
+		if q.BitLen() > ecppSmallBits {
+			if cert = ProveNMinus1(q); cert == nil {
+				continue
+			}
+		} else if !SimpleProof(q) {
+			continue
+		}
+		a := nMinus1Witness(N, q)
+		if a == nil {
+			return nil, nil
+		}
+		fs = append(fs, NMinus1Factor{Q: q, E: E[i], A: a, Cert: cert})
+		F.Mul(F, qe.Exp(q, new(big.Int).SetUint64(E[i]), nil))
+	}
+	return
+}
+
+// VerifyNMinus1 checks that c is a valid N-1 certificate.
+func VerifyNMinus1(c *NMinus1) bool {
+	// Step 0: parse input / easy cases
+	if c == nil || c.N == nil || c.N.Cmp(two) < 0 {
+		return false
+	}
+	N := c.N
+	if N.Cmp(two) == 0 {
+		return true
+	}
+
+	// Step 1: check each factor is a prime
+	// power exactly dividing N-1 with a witness
+	F := big.NewInt(1)
+	for _, f := range c.Factors {
+		qe := verifyNMinus1Factor(N, f)
+		if qe == nil {
+			return false
+		}
+		F.Mul(F, qe)
+	}
+
+	// Step 2: F divides N-1 and is big enough
+	if new(big.Int).Mod(new(big.Int).Sub(N, one), F).Sign() != 0 {
+		return false
+	}
+	return nMinus1Bound(N, F)
+}
+
+// verifyNMinus1Factor checks f is a proven prime power
+// exactly dividing N-1 with a valid witness. It returns
+// Q^E or nil if any check fails.
+func verifyNMinus1Factor(N *big.Int, f NMinus1Factor) *big.Int {
+	if f.Q == nil || f.A == nil || f.E == 0 || f.Q.Cmp(two) < 0 {
+		return nil
+	}
+	if f.Q.BitLen() > ecppSmallBits {
+		if f.Cert == nil || f.Cert.N == nil || f.Cert.N.Cmp(f.Q) != 0 || f.Q.Cmp(N) != -1 || !VerifyNMinus1(f.Cert) {
+			return nil
+		}
+	} else if !SimpleProof(f.Q) {
+		return nil
+	}
+	nm1 := new(big.Int).Sub(N, one)
+	qe := new(big.Int).Exp(f.Q, new(big.Int).SetUint64(f.E), nil)
+	z, r := new(big.Int).QuoRem(nm1, qe, new(big.Int))
+	if r.Sign() != 0 || r.Mod(z, f.Q).Sign() == 0 {
+		return nil
+	}
+	// A^(N-1) = 1 and gcd(A^((N-1)/Q) - 1, N) = 1
+	if z.Exp(f.A, nm1, N).Cmp(one) != 0 {
+		return nil
+	}
+	z.Exp(f.A, z.Quo(nm1, f.Q), N)
+	if z.GCD(nil, nil, z.Sub(z, one), N).Cmp(one) != 0 {
+		return nil
+	}
+	return qe
+}
+
+// nMinus1Bound returns true if knowing every prime factor of N
+// is 1 mod F is enough to show N is prime. That is if F^2 >= N,
+// or if F^3 >= N and writing N = c2 F^2 + c1 F + 1 with
+// 0 <= c1 < F, c1^2 - 4c2 is not a square.
+// See Crandall and Pomerance, Theorem 4.1.6.
+func nMinus1Bound(N, F *big.Int) bool {
+	if new(big.Int).Mul(F, F).Cmp(N) >= 0 {
+		return true
+	}
+	if new(big.Int).Exp(F, big.NewInt(3), nil).Cmp(N) == -1 {
+		return false
+	}
+	c2, c1 := new(big.Int).QuoRem(new(big.Int).Sub(N, one), F, new(big.Int))
+	c2, c1 = c2.QuoRem(c2, F, c1)
+	d := new(big.Int).Mul(c1, c1)
+	d.Sub(d, c2.Lsh(c2, 2))
+	return !IsSquare(d)
+}
+
+// nMinus1Witness returns some a with a^(N-1) = 1 and
+// gcd(a^((N-1)/q) - 1, N) = 1 or nil if N is composite
+// or no such a is found.
+func nMinus1Witness(N, q *big.Int) *big.Int {
+	nm1 := new(big.Int).Sub(N, one)
+	e := new(big.Int).Quo(nm1, q)
+	z := new(big.Int)
+	for a := big.NewInt(2); a.Cmp(N) == -1 && a.BitLen() <= 16; a.Add(a, one) {
+		if z.Exp(a, nm1, N).Cmp(one) != 0 {
+			return nil
+		}
+		z.Exp(a, e, N)
+		switch z.GCD(nil, nil, z.Sub(z, one), N).Cmp(one) {
+		case 0:
+			return a
+		case 1:
+			// a^((N-1)/q) = 1 or N is composite
+			if z.Cmp(N) != 0 {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// primePowers partially factors M and returns the probable
+// primes q found in increasing order along with the
+// exponent e such that q^e exactly divides M.
+func primePowers(M *big.Int) (Q []*big.Int, E []uint64) {
+	F, _ := partialFactor(M)
+	for _, pe := range F {
+		Q = append(Q, pe.P)
+	}
+	z := new(big.Int)
+	for _, q := range Q {
+		// q may also divide the unfactored part
+		// so count its full exponent in M
+		var e uint64
+		for r := new(big.Int).Set(M); z.Mod(r, q).Sign() == 0; e++ {
+			r.Quo(r, q)
+		}
+		E = append(E, e)
+	}
+	return
+}
+
+// partialFactor finds probable prime factors of N with
+// trial division then Pollard rho on the composite cofactors.
+// It returns the factors found and the unfactored part R.
+func partialFactor(N *big.Int) (F Factorization, R *big.Int) {
+	F, r := trialDivide(N)
+	R = big.NewInt(1)
+	stack := []*big.Int{r}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n.Cmp(one) == 0 {
+			continue
+		}
+		if BPSW(n) != IsComposite {
+			F.add(n, 1)
+			continue
+		}
+		if IsSquare(n) {
+			s := new(big.Int).Sqrt(n)
+			stack = append(stack, s, s)
+			continue
+		}
+		var d *big.Int
+		for c := int64(1); c <= 3 && d == nil; c++ {
+			d = brentRho(n, c, partialRhoLimit)
+		}
+		if d == nil {
+			R.Mul(R, n)
+			continue
+		}
+		stack = append(stack, d, new(big.Int).Quo(n, d))
+	}
+	return
+}
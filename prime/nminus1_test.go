@@ -0,0 +1,96 @@
+package prime
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrentRho(t *testing.T) {
+	cases := []*big.Int{
+		big.NewInt(8051),
+		big.NewInt(10403),
+		big.NewInt(1000003 * 1000033),
+		new(big.Int).Mul(big.NewInt(2147483647), big.NewInt(4294967291)),
+	}
+	for _, N := range cases {
+		d := brentRho(N, 1, 1<<20)
+		require.NotNil(t, d, fmt.Sprintf("N=%d", N))
+		assert.Equal(t, 0, new(big.Int).Mod(N, d).Sign(), fmt.Sprintf("N=%d, d=%d", N, d))
+		assert.True(t, d.Cmp(one) == 1 && d.Cmp(N) == -1, fmt.Sprintf("N=%d, d=%d", N, d))
+	}
+}
+
+func TestProveNMinus1(t *testing.T) {
+	M127 := new(big.Int).Sub(new(big.Int).Lsh(one, 127), one)
+	cases := []struct {
+		in   *big.Int
+		want bool
+	}{
+		{big.NewInt(1), false},
+		{big.NewInt(2), true},
+		{big.NewInt(3), true},
+		{big.NewInt(1709), true},
+		{big.NewInt(2047), false},
+		{big.NewInt(2305843009213693951), true}, // 2^61 - 1
+		{M127, true},
+		{new(big.Int).Mul(M127, big.NewInt(2147483647)), false},
+	}
+	for _, c := range cases {
+		cert := ProveNMinus1(c.in)
+		assert.Equal(t, c.want, cert != nil, fmt.Sprintf("in=%d", c.in))
+		if cert != nil {
+			assert.True(t, VerifyNMinus1(cert), fmt.Sprintf("in=%d", c.in))
+		}
+	}
+	// primes of the form 1 + 2*(product of small primes)
+	N := big.NewInt(2)
+	for i := 1; N.BitLen() < 512; i++ {
+		N.Mul(N, big.NewInt(int64(primes16[i])))
+		p := new(big.Int).Add(N, one)
+		if BPSW(p) == IsComposite {
+			continue
+		}
+		cert := ProveNMinus1(p)
+		require.NotNil(t, cert, fmt.Sprintf("p=%d", p))
+		require.True(t, VerifyNMinus1(cert), fmt.Sprintf("p=%d", p))
+	}
+}
+
+func TestNMinus1Bound(t *testing.T) {
+	// 1709 - 1 = 2^2 * 7 * 61
+	cases := []struct {
+		N, F int64
+		want bool
+	}{
+		{1709, 61 * 7, true},
+		{1709, 28, true},
+		{1709, 4, false},
+		// 341 = 11 * 31 = (1*10 + 1)(3*10 + 1)
+		{341, 10, false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, nMinus1Bound(big.NewInt(c.N), big.NewInt(c.F)), fmt.Sprintf("N=%d, F=%d", c.N, c.F))
+	}
+}
+
+func TestVerifyNMinus1(t *testing.T) {
+	cert := ProveNMinus1(big.NewInt(2305843009213693951))
+	require.NotNil(t, cert)
+	require.True(t, VerifyNMinus1(cert))
+	bad := *cert
+	bad.N = big.NewInt(2305843009213693953)
+	assert.False(t, VerifyNMinus1(&bad))
+	bad = *cert
+	bad.Factors = append([]NMinus1Factor(nil), cert.Factors...)
+	bad.Factors[0].E++
+	assert.False(t, VerifyNMinus1(&bad))
+	bad.Factors[0] = cert.Factors[0]
+	bad.Factors[0].A = big.NewInt(1)
+	assert.False(t, VerifyNMinus1(&bad))
+	assert.False(t, VerifyNMinus1(&NMinus1{N: big.NewInt(1711)}))
+	assert.False(t, VerifyNMinus1(nil))
+}
@@ -0,0 +1,218 @@
+package prime
+
+import (
+	"math/big"
+)
+
+// NPlus1 is an N+1 primality certificate for N.
+// Each factor Q^E exactly divides N+1 and has a Lucas
+// parameter P so that with Q' = (P^2 - D)/4 the Lucas
+// sequence satisfies U_{N+1} = 0 and gcd(U_{(N+1)/Q}, N) = 1.
+// Every prime factor p of N is then 1 or -1 mod F2, the
+// product of the factors. If Minus is not empty it is the
+// N-1 part of a combined test, every p is also 1 mod F1.
+//
+// See Brillhart, Lehmer and Selfridge, "New Primality Criteria
+// and Factorizations of 2^m ± 1" (1975), Theorems 14 and 7.
+type NPlus1 struct {
+	N       *big.Int
+	D       *big.Int
+	Factors []NPlus1Factor
+	Minus   []NMinus1Factor
+}
+
+// NPlus1Factor is a prime power Q^E exactly dividing
+// N+1 with a Lucas parameter P. If Q has more than 32 bits
+// then Cert is a certificate that Q is prime.
+type NPlus1Factor struct {
+	Q    *big.Int
+	E    uint64
+	P    *big.Int
+	Cert *NPlus1
+}
+
+// ProveNPlus1 returns an N+1 certificate for N or nil if
+// N is composite or not enough of N+1 could be factored.
+// If the factored part of N+1 is too small it also
+// factors N-1 and tries the combined N-1/N+1 test.
+func ProveNPlus1(N *big.Int) *NPlus1 {
+	// Step 0: parse input / easy cases
+	if N.Cmp(two) < 0 || BPSW(N) == IsComposite {
+		return nil
+	}
+	c := &NPlus1{N: new(big.Int).Set(N)}
+	if N.Cmp(two) == 0 {
+		return c
+	}
+
+	// Step 1: find D with (D/N) = -1 as in
+	// Selfridge's method for StrongLucasSelfridge
+	c.D = big.NewInt(5)
+	for big.Jacobi(new(big.Int).Mod(c.D, N), N) != -1 {
+		if c.D.Sign() < 0 {
+			c.D.Sub(c.D, two)
+		} else {
+			c.D.Add(c.D, two)
+		}
+		c.D.Neg(c.D)
+	}
+
+	// Step 2: factor N+1 and find Lucas parameters
+	F2 := big.NewInt(1)
+	qe := new(big.Int)
+	Q, E := primePowers(new(big.Int).Add(N, one))
+	for i, q := range Q {
+		var cert *NPlus1
+		if q.BitLen() > ecppSmallBits {
+			if cert = ProveNPlus1(q); cert == nil {
+				continue
+			}
+		} else if !SimpleProof(q) {
+			continue
+		}
+		P := nPlus1Witness(N, c.D, q)
+		if P == nil {
+			return nil
+		}
+		c.Factors = append(c.Factors, NPlus1Factor{Q: q, E: E[i], P: P, Cert: cert})
+		F2.Mul(F2, qe.Exp(q, new(big.Int).SetUint64(E[i]), nil))
+	}
+	if nPlus1Bound(N, big.NewInt(1), F2) {
+		return c
+	}
+
+	// Step 3: use the combined test with N-1 as well
+	var F1 *big.Int
+	if c.Minus, F1 = nMinus1Factors(N); F1 == nil {
+		return nil
+	}
+	if !nPlus1Bound(N, F1, F2) {
+		return nil
+	}
+	return c
+}
+
+// VerifyNPlus1 checks that c is a valid N+1 certificate.
+func VerifyNPlus1(c *NPlus1) bool {
+	// Step 0: parse input / easy cases
+	if c == nil || c.N == nil || c.N.Cmp(two) < 0 {
+		return false
+	}
+	N := c.N
+	if N.Cmp(two) == 0 {
+		return true
+	}
+	if N.Bit(0) == 0 {
+		return false
+	}
+
+	// Step 1: check the factors of N+1
+	F2 := big.NewInt(1)
+	if len(c.Factors) > 0 {
+		if c.D == nil || big.Jacobi(new(big.Int).Mod(c.D, N), N) != -1 {
+			return false
+		}
+	}
+	for _, f := range c.Factors {
+		qe := verifyNPlus1Factor(N, c.D, f)
+		if qe == nil {
+			return false
+		}
+		F2.Mul(F2, qe)
+	}
+	if new(big.Int).Mod(new(big.Int).Add(N, one), F2).Sign() != 0 {
+		return false
+	}
+
+	// Step 2: check the factors of N-1
+	F1 := big.NewInt(1)
+	for _, f := range c.Minus {
+		qe := verifyNMinus1Factor(N, f)
+		if qe == nil {
+			return false
+		}
+		F1.Mul(F1, qe)
+	}
+	if new(big.Int).Mod(new(big.Int).Sub(N, one), F1).Sign() != 0 {
+		return false
+	}
+
+	// Step 3: make sure F1 and F2 are big enough
+	return nPlus1Bound(N, F1, F2)
+}
+
+// verifyNPlus1Factor checks f is a proven prime power
+// exactly dividing N+1 with a valid Lucas parameter.
+// It returns Q^E or nil if any check fails.
+func verifyNPlus1Factor(N, D *big.Int, f NPlus1Factor) *big.Int {
+	if f.Q == nil || f.P == nil || f.E == 0 || f.Q.Cmp(two) < 0 {
+		return nil
+	}
+	if f.Q.BitLen() > ecppSmallBits {
+		if f.Cert == nil || f.Cert.N == nil || f.Cert.N.Cmp(f.Q) != 0 || f.Q.Cmp(N) != -1 || !VerifyNPlus1(f.Cert) {
+			return nil
+		}
+	} else if !SimpleProof(f.Q) {
+		return nil
+	}
+	np1 := new(big.Int).Add(N, one)
+	qe := new(big.Int).Exp(f.Q, new(big.Int).SetUint64(f.E), nil)
+	z, r := new(big.Int).QuoRem(np1, qe, new(big.Int))
+	if r.Sign() != 0 || r.Mod(z, f.Q).Sign() == 0 {
+		return nil
+	}
+	if !lucasWitness(N, D, f.P, f.Q) {
+		return nil
+	}
+	return qe
+}
+
+// lucasWitness returns true if the Lucas sequence with
+// parameters P and (P^2 - D)/4 has gcd(N, 2QD) = 1,
+// U_{N+1} = 0 and gcd(U_{(N+1)/q}, N) = 1 mod N.
+func lucasWitness(N, D, P, q *big.Int) bool {
+	Q := new(big.Int).Mul(P, P)
+	Q.Sub(Q, D)
+	if Q.Bit(0) != 0 || Q.Bit(1) != 0 {
+		return false
+	}
+	Q.Rsh(Q, 2)
+	z := new(big.Int).Mul(Q, D)
+	if z.GCD(nil, nil, z.Abs(z.Lsh(z, 1)), N).Cmp(one) != 0 {
+		return false
+	}
+	Q.Mod(Q, N)
+	np1 := new(big.Int).Add(N, one)
+	if U, _, _ := lucasSequence(P, Q, D, np1, N); U.Sign() != 0 {
+		return false
+	}
+	U, _, _ := lucasSequence(P, Q, D, z.Quo(np1, q), N)
+	return U.GCD(nil, nil, U, N).Cmp(one) == 0
+}
+
+// nPlus1Witness returns a Lucas parameter P for q
+// or nil if none is found.
+func nPlus1Witness(N, D, q *big.Int) *big.Int {
+	// P must be odd so that P^2 = D = 1 mod 4
+	for P := big.NewInt(1); P.BitLen() <= 16; P.Add(P, two) {
+		if lucasWitness(N, D, P, q) {
+			return P
+		}
+	}
+	return nil
+}
+
+// nPlus1Bound returns true if knowing every prime factor
+// of N is 1 mod F1 and 1 or -1 mod F2 is enough to show
+// N is prime. With F = lcm(F1, F2) every prime factor p is
+// 1 or N mod F, so if F^2 > N a p below sqrt(N) would have
+// to be N mod F itself.
+func nPlus1Bound(N, F1, F2 *big.Int) bool {
+	F := new(big.Int).GCD(nil, nil, F1, F2)
+	F.Mul(F1, F.Quo(F2, F))
+	if new(big.Int).Mul(F, F).Cmp(N) != 1 {
+		return false
+	}
+	r := new(big.Int).Mod(N, F)
+	return r.Cmp(one) <= 0 || r.Cmp(N) == 0 || new(big.Int).Mod(N, r).Sign() != 0
+}
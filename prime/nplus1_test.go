@@ -0,0 +1,103 @@
+package prime
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLucasSequence(t *testing.T) {
+	// P = 1, Q = -1 gives the fibonacci and lucas numbers
+	N := big.NewInt(1000003)
+	P, Q, D := big.NewInt(1), big.NewInt(-1), big.NewInt(5)
+	fib := []int64{0, 1, 1, 2, 3, 5, 8, 13, 21, 34, 55}
+	luc := []int64{2, 1, 3, 4, 7, 11, 18, 29, 47, 76, 123}
+	for k := range fib {
+		U, V, Qk := lucasSequence(P, new(big.Int).Mod(Q, N), D, big.NewInt(int64(k)), N)
+		assert.Equal(t, big.NewInt(fib[k]), U, fmt.Sprintf("k=%d", k))
+		assert.Equal(t, big.NewInt(luc[k]), V, fmt.Sprintf("k=%d", k))
+		assert.Equal(t, new(big.Int).Exp(new(big.Int).Mod(Q, N), big.NewInt(int64(k)), N), Qk, fmt.Sprintf("k=%d", k))
+	}
+}
+
+func TestProveNPlus1(t *testing.T) {
+	M127 := new(big.Int).Sub(new(big.Int).Lsh(one, 127), one)
+	cases := []struct {
+		in   *big.Int
+		want bool
+	}{
+		{big.NewInt(1), false},
+		{big.NewInt(2), true},
+		{big.NewInt(3), true},
+		{big.NewInt(1709), true},
+		{big.NewInt(2047), false},
+		{big.NewInt(2305843009213693951), true}, // 2^61 - 1
+		{M127, true},
+		{new(big.Int).Mul(M127, big.NewInt(2147483647)), false},
+	}
+	for _, c := range cases {
+		cert := ProveNPlus1(c.in)
+		assert.Equal(t, c.want, cert != nil, fmt.Sprintf("in=%d", c.in))
+		if cert != nil {
+			assert.True(t, VerifyNPlus1(cert), fmt.Sprintf("in=%d", c.in))
+		}
+	}
+	// N+1 = 2^127 so the N+1 test alone is enough
+	cert := ProveNPlus1(M127)
+	require.NotNil(t, cert)
+	assert.Empty(t, cert.Minus)
+	for i := 0; i < 10; i++ {
+		p := RandPrime(64)
+		require.True(t, VerifyNPlus1(ProveNPlus1(p)), fmt.Sprintf("p=%d", p))
+	}
+}
+
+func TestVerifyNPlus1Combined(t *testing.T) {
+	// 1000151 - 1 = 2 * 5^2 * 83 * 241
+	// 1000151 + 1 = 2^3 * 3^2 * 29 * 479
+	N := big.NewInt(1000151)
+	cert := ProveNPlus1(N)
+	require.NotNil(t, cert)
+	require.True(t, VerifyNPlus1(cert))
+	// keep 2^3 * 3^2 = 72 of N+1 which is too small
+	// and add 5^2 = 25 of N-1, together they
+	// give lcm = 1800 > sqrt(N)
+	var plus []NPlus1Factor
+	for _, f := range cert.Factors {
+		if f.Q.Int64() <= 3 {
+			plus = append(plus, f)
+		}
+	}
+	combined := &NPlus1{N: N, D: cert.D, Factors: plus}
+	assert.False(t, VerifyNPlus1(combined))
+	minus, _ := nMinus1Factors(N)
+	for _, f := range minus {
+		if f.Q.Int64() == 5 {
+			combined.Minus = append(combined.Minus, f)
+		}
+	}
+	assert.True(t, VerifyNPlus1(combined))
+	// N-1 alone is not enough
+	assert.False(t, VerifyNPlus1(&NPlus1{N: N, Minus: combined.Minus}))
+}
+
+func TestVerifyNPlus1(t *testing.T) {
+	cert := ProveNPlus1(big.NewInt(2305843009213693951))
+	require.NotNil(t, cert)
+	require.True(t, VerifyNPlus1(cert))
+	bad := *cert
+	bad.N = big.NewInt(2305843009213693953)
+	assert.False(t, VerifyNPlus1(&bad))
+	bad = *cert
+	bad.D = big.NewInt(4)
+	assert.False(t, VerifyNPlus1(&bad))
+	bad = *cert
+	bad.Factors = append([]NPlus1Factor(nil), cert.Factors...)
+	bad.Factors[0].E++
+	assert.False(t, VerifyNPlus1(&bad))
+	assert.False(t, VerifyNPlus1(&NPlus1{N: big.NewInt(1711)}))
+	assert.False(t, VerifyNPlus1(nil))
+}
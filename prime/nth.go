@@ -0,0 +1,118 @@
+package prime
+
+import (
+	"context"
+	"errors"
+	"math"
+)
+
+const (
+	// pi(2^64 - 1), the largest n with an nth prime below 2^64
+	nthMax = 425656284035217743
+	// the largest prime below 2^64
+	nthMaxPrime = 1<<64 - 59
+)
+
+// Nth returns the nth prime, so Nth(1) = 2. It returns 0
+// if n is 0 or the nth prime is not below 2^64.
+func Nth(n uint64) uint64 {
+	p, _ := NthContext(context.Background(), n)
+	return p
+}
+
+// NthContext is Nth but gives up with ctx.Err() once ctx is done.
+func NthContext(ctx context.Context, n uint64) (uint64, error) {
+	// Step 0: parse input / easy cases
+	if n == 0 {
+		return 0, errors.New("prime: there is no 0th prime")
+	}
+	if n > nthMax {
+		return 0, errors.New("prime: the nth prime is not below 2^64")
+	}
+
+	// Step 1: p_n is in [lo, hi], and pi(lo) = c < n
+	lo, hi := nthBounds(n)
+	lo--
+	c, err := CountContext(ctx, lo)
+	if err != nil {
+		return 0, err
+	}
+
+	// Step 2: pi(x) takes about x^(2/3) steps, so while sieving
+	// the rest would take longer, guess the n - c primes left end
+	// about d = (n - c) ln(lo) past lo, and count again a little
+	// before that, the number of primes in an interval of length
+	// d is within a few sqrt(d / ln(lo)) of d / ln(lo)
+	for {
+		w := max(countSieveLimit, cbrt64(hi)*cbrt64(hi)/8)
+		if hi >= 1<<(2*sieveBaseBits) {
+			// Sieve tests what is left with Miller-Rabin up there
+			w /= 32
+		}
+		ln := math.Log(float64(lo))
+		d := uint64(min(float64(n-c)*ln, float64(hi-lo)))
+		if min(d, hi-lo) <= w {
+			break
+		}
+		x := lo + d - min(w/2, uint64(16*math.Sqrt(float64(d)*ln)))
+		if d == hi-lo {
+			x = lo + (hi-lo)/2
+		}
+		e, err := CountContext(ctx, x)
+		if err != nil {
+			return 0, err
+		}
+		if e < n {
+			lo, c = x, e
+		} else {
+			hi = x
+		}
+	}
+
+	// Step 3: sieve the rest
+	var p uint64
+	i := 0
+	Sieve(lo+1, hi+1, func(q uint64) bool {
+		if i%ctxCheckInterval == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+		i++
+		c++
+		p = q
+		return c < n
+	})
+	if err != nil {
+		return 0, err
+	}
+	return p, nil
+}
+
+// nthBounds returns lo <= p_n <= hi for 0 < n <= nthMax using
+// Dusart, "Estimates of some functions over primes without
+// R.H.", 2010:
+//
+//	p_n >= n(ln n + ln ln n - 1 + (ln ln n - 2.1)/ln n)   for n >= 3
+//	p_n <= n(ln n + ln ln n - 1 + (ln ln n - 2)/ln n)     for n >= 688383
+//	p_n <= n(ln n + ln ln n)                              for n >= 6
+func nthBounds(n uint64) (lo, hi uint64) {
+	if n < 6 {
+		return 2, 11
+	}
+	f := float64(n)
+	ln := math.Log(f)
+	lnln := math.Log(ln)
+	l := f * (ln + lnln - 1 + (lnln-2.1)/ln)
+	h := f * (ln + lnln)
+	if n >= 688383 {
+		h = f * (ln + lnln - 1 + (lnln-2)/ln)
+	}
+	// leave room for rounding in float64
+	lo = uint64(max(2, l*(1-1e-12)-1))
+	hi = nthMaxPrime
+	if h = h*(1+1e-12) + 1; h < nthMaxPrime {
+		hi = uint64(h)
+	}
+	return
+}
@@ -0,0 +1,69 @@
+package prime
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNth(t *testing.T) {
+	tests := []struct {
+		n, p uint64
+	}{
+		{0, 0},
+		{1, 2},
+		{2, 3},
+		{5, 11},
+		{6, 13},
+		{100, 541},
+		{1000, 7919},
+		{688383, 10384261},
+		{1000000, 15485863},
+		{10000000, 179424673},
+		{100000000, 2038074743},
+		{1000000000, 22801763489},
+		{10000000000, 252097800623},
+		{nthMax + 1, 0},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.p, Nth(test.n), "p_%d", test.n)
+	}
+}
+
+func TestNthCount(t *testing.T) {
+	for _, lo := range []uint64{0, 1000000, 1 << 32} {
+		n := Count(lo)
+		Sieve(lo+1, lo+1000, func(p uint64) bool {
+			n++
+			assert.Equal(t, p, Nth(n), "p_%d", n)
+			return true
+		})
+	}
+}
+
+func TestNthBounds(t *testing.T) {
+	n := uint64(0)
+	Sieve(0, 20000000, func(p uint64) bool {
+		n++
+		lo, hi := nthBounds(n)
+		if lo > p || p > hi {
+			assert.Fail(t, "bounds", "%d <= p_%d = %d <= %d", lo, n, p, hi)
+			return false
+		}
+		return true
+	})
+}
+
+func TestNthContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// small enough that only the final sieve runs
+	_, err := NthContext(ctx, 1000)
+	assert.Equal(t, context.Canceled, err)
+	_, err = NthContext(ctx, 1e15)
+	assert.Equal(t, context.Canceled, err)
+	p, err := NthContext(context.Background(), 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7919), p)
+}
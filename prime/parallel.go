@@ -0,0 +1,135 @@
+package prime
+
+import (
+	"context"
+	"io"
+	"math"
+	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// the width of the intervals NextPrimeParallel hands
+// out to workers, small compared to prime gaps of
+// the sizes worth searching in parallel
+const parallelWidth = 256
+
+// NextPrimeParallel is NextPrimeContext with the candidates
+// tested by a pool of workers. It still returns the smallest
+// probable prime p >= N. If workers <= 0 it uses GOMAXPROCS.
+func NextPrimeParallel(ctx context.Context, N *big.Int, workers int) (*big.Int, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers == 1 || N.BitLen() <= 10 {
+		return NextPrimeContext(ctx, N)
+	}
+	// Each worker takes the next interval [N + cw, N + (c+1)w)
+	// and looks for its first prime. Once interval c has one,
+	// no one starts or continues an interval after c, and
+	// every interval before c has already been handed out.
+	var next, best atomic.Int64
+	best.Store(math.MaxInt64)
+	var mu sync.Mutex
+	var found *big.Int
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lo, hi := new(big.Int), new(big.Int)
+			for {
+				c := next.Add(1) - 1
+				stop := func() bool { return c > best.Load() }
+				if stop() {
+					return
+				}
+				lo.Add(N, lo.SetInt64(c*parallelWidth))
+				hi.Add(lo, hi.SetInt64(parallelWidth))
+				p, err := firstPrimeIn(ctx, lo, hi, stop)
+				if err != nil {
+					return
+				}
+				if p != nil {
+					mu.Lock()
+					if c < best.Load() {
+						best.Store(c)
+						found = p
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	// an interval before found may have been cut short
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// PrimeParallel is PrimeContext with a pool of workers
+// each searching from its own random start, the first
+// prime found wins. Unlike Prime the result is not
+// determined by the bytes read from random.
+// If workers <= 0 it uses GOMAXPROCS.
+func PrimeParallel(ctx context.Context, random io.Reader, bits, workers int) (*big.Int, error) {
+	return race(ctx, random, workers, func(ctx context.Context, random io.Reader) (*big.Int, error) {
+		return PrimeContext(ctx, random, bits)
+	})
+}
+
+// SafePrimeParallel is SafePrimeContext run by a pool
+// of workers in the same way as PrimeParallel.
+func SafePrimeParallel(ctx context.Context, random io.Reader, bits, workers int) (*big.Int, error) {
+	return race(ctx, random, workers, func(ctx context.Context, random io.Reader) (*big.Int, error) {
+		return SafePrimeContext(ctx, random, bits)
+	})
+}
+
+// race runs search in workers goroutines sharing random
+// and returns the first result. The others are cancelled
+// and waited for, so random is free again on return.
+func race(ctx context.Context, random io.Reader, workers int, search func(context.Context, io.Reader) (*big.Int, error)) (*big.Int, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers == 1 {
+		return search(ctx, random)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	random = &lockedReader{r: random}
+	type result struct {
+		p   *big.Int
+		err error
+	}
+	results := make(chan result, workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			p, err := search(ctx, random)
+			results <- result{p, err}
+		}()
+	}
+	// the first result wins, even if it is an error
+	r := <-results
+	cancel()
+	for w := 1; w < workers; w++ {
+		<-results
+	}
+	return r.p, r.err
+}
+
+// lockedReader lets several goroutines share a reader.
+type lockedReader struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+func (l *lockedReader) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Read(p)
+}
@@ -0,0 +1,79 @@
+package prime
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextPrimeParallel(t *testing.T) {
+	cases := []*big.Int{
+		big.NewInt(0),
+		big.NewInt(17),
+		big.NewInt(1700000),
+		new(big.Int).Lsh(one, 64),
+		// the gap after 1693182318746371 is 1132
+		big.NewInt(1693182318746372),
+	}
+	for i := 0; i < 10; i++ {
+		cases = append(cases, randBig(256))
+	}
+	require.Equal(t, big.NewInt(1693182318747503), NextPrime(cases[4]))
+	for _, N := range cases {
+		want := NextPrime(N)
+		for _, workers := range []int{0, 1, 3, 8} {
+			p, err := NextPrimeParallel(context.Background(), N, workers)
+			require.NoError(t, err)
+			assert.Equal(t, want, p, fmt.Sprintf("N=%d, workers=%d", N, workers))
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := NextPrimeParallel(ctx, randBig(1024), 4)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestPrimeParallel(t *testing.T) {
+	for _, bits := range []int{2, 10, 64, 512} {
+		p, err := PrimeParallel(context.Background(), rand.Reader, bits, 4)
+		require.NoError(t, err)
+		assert.Equal(t, bits, p.BitLen())
+		assert.NotEqual(t, IsComposite, BPSW(p))
+		p, err = SafePrimeParallel(context.Background(), NewDRBG([]byte("seed")), bits+2, 4)
+		require.NoError(t, err)
+		assert.Equal(t, bits+2, p.BitLen())
+		assert.NotEqual(t, IsComposite, BPSW(new(big.Int).Rsh(p, 1)))
+	}
+	_, err := PrimeParallel(context.Background(), errReader{}, 512, 4)
+	assert.Error(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = PrimeParallel(ctx, rand.Reader, 1024, 4)
+	assert.Equal(t, context.Canceled, err)
+	// no worker is left reading random
+	r := &countingReader{r: rand.Reader}
+	_, err = PrimeParallel(context.Background(), r, 1024, 8)
+	require.NoError(t, err)
+	n := r.n.Load()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, n, r.n.Load())
+}
+
+// countingReader counts the calls to Read.
+type countingReader struct {
+	n atomic.Int64
+	r io.Reader
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.n.Add(1)
+	return c.r.Read(p)
+}
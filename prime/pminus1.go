@@ -0,0 +1,209 @@
+package prime
+
+import (
+	"context"
+	"math/big"
+)
+
+// PMinus1 looks for a factor of N with Pollard's p-1 method.
+// It finds a prime p dividing N when p-1 is a product of
+// prime powers at most B1 and at most one more prime in
+// (B1, B2]. It returns nil if no factor was found, or if
+// every prime factor of N was found at once.
+//
+// See Pollard, "Theorems on factorization and primality testing" (1974).
+func PMinus1(N *big.Int, B1, B2 uint64) *big.Int {
+	d, _ := PMinus1Context(context.Background(), N, B1, B2)
+	return d
+}
+
+// PMinus1Context is PMinus1 but checks ctx during both
+// stages and stops with ctx.Err() once it is done.
+func PMinus1Context(ctx context.Context, N *big.Int, B1, B2 uint64) (*big.Int, error) {
+	// Step 0: parse input / easy cases
+	if N.Cmp(big.NewInt(4)) < 0 {
+		return nil, nil
+	}
+	if N.Bit(0) == 0 {
+		return big.NewInt(2), nil
+	}
+
+	// Step 1: x = 2^E mod N where E is the product
+	// of the prime powers at most B1
+	x := big.NewInt(2)
+	pow := func(x, k *big.Int) { x.Exp(x, k, N) }
+	if g, err := smoothStage1(ctx, N, x, B1, one, pow); g != nil || err != nil {
+		return nontrivial(g, N), err
+	}
+
+	// Step 2: with P = x + 1/x the Lucas sequence
+	// V_n(P) = x^n + x^-n so stage 2 is shared with p+1
+	P := new(big.Int).ModInverse(x, N)
+	P.Add(P, x)
+	g, err := lucasStage2(ctx, N, P.Mod(P, N), B1, B2)
+	return nontrivial(g, N), err
+}
+
+// smoothStage1 sets x to pow(x, E) where E is the product of
+// the prime powers at most B1 and the primes up to 10, which
+// lucasStage2 skips, batching the prime powers and
+// checking gcd(x - s, N) after each batch. It returns the
+// first gcd which is not 1, or nil once every prime is done.
+// If a batch finds every prime factor of N at once it is
+// redone one prime power at a time. Between batches it
+// stops with ctx.Err() once ctx is done.
+func smoothStage1(ctx context.Context, N, x *big.Int, B1 uint64, s *big.Int, pow func(x, k *big.Int)) (*big.Int, error) {
+	const batchSize = 64
+	g := new(big.Int)
+	k := new(big.Int)
+	z := new(big.Int)
+	saved := new(big.Int)
+	batch := make([]uint64, 0, batchSize)
+	flush := func() *big.Int {
+		defer func() { batch = batch[:0] }()
+		saved.Set(x)
+		k.SetUint64(1)
+		for _, q := range batch {
+			k.Mul(k, z.SetUint64(q))
+		}
+		pow(x, k)
+		if g.GCD(nil, nil, z.Sub(x, s), N).Cmp(one) == 0 {
+			return nil
+		}
+		if g.Cmp(N) == 0 {
+			// the batch found every factor at once so
+			// redo it from before one step at a time
+			x.Set(saved)
+			for _, q := range batch {
+				pow(x, k.SetUint64(q))
+				if g.GCD(nil, nil, z.Sub(x, s), N).Cmp(one) != 0 {
+					break
+				}
+			}
+		}
+		return g
+	}
+	var d *big.Int
+	var err error
+	Sieve(2, max(B1, 10)+1, func(q uint64) bool {
+		Q := q
+		for Q <= B1/q {
+			Q *= q
+		}
+		batch = append(batch, Q)
+		if len(batch) == batchSize {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+			d = flush()
+		}
+		return d == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if d == nil && len(batch) > 0 {
+		d = flush()
+	}
+	return d, nil
+}
+
+// lucasStage2 looks for a prime q in (B1, B2] with
+// V_q(P) = 2 mod p for a prime p dividing N, where V is
+// the Lucas sequence with Q = 1. With D = 210 every such q
+// is kD - j or kD + j for some 0 < j < D/2 coprime to D and
+// V_kD - V_j = 0 mod p exactly when one of them works, so
+// pairs of primes share a single multiplication.
+// It returns the gcd of N and the product, which is 1 if
+// nothing was found, or ctx.Err() once ctx is done.
+//
+// See Montgomery, "Speeding the Pollard and elliptic curve
+// methods of factorization" (1987).
+func lucasStage2(ctx context.Context, N, P *big.Int, B1, B2 uint64) (*big.Int, error) {
+	const D = 210
+	// Step 0: parse input / easy cases
+	g := big.NewInt(1)
+	if B2 <= B1 {
+		return g, nil
+	}
+	lucasV := func(P *big.Int, k uint64) *big.Int {
+		D := new(big.Int).Mul(P, P)
+		D.Sub(D, big.NewInt(4))
+		_, V, _ := lucasSequence(P, one, D, new(big.Int).SetUint64(k), N)
+		return V
+	}
+
+	// Step 1: baby steps V_j for odd j < D/2 using
+	// V_{j+2} = V_j V_2 - V_{j-2} and V_-1 = V_1
+	var baby [D / 2]*big.Int
+	V2 := lucasV(P, 2)
+	prev, cur := new(big.Int).Set(P), new(big.Int).Set(P)
+	for j := 1; j < D/2; j += 2 {
+		if j%3 != 0 && j%5 != 0 && j%7 != 0 {
+			baby[j] = new(big.Int).Set(cur)
+		}
+		next := new(big.Int).Mul(cur, V2)
+		next.Sub(next, prev)
+		prev, cur = cur, next.Mod(next, N)
+	}
+
+	// Step 2: giant steps V_kD using
+	// V_{(k+1)D} = V_kD V_D - V_{(k-1)D}
+	lo := max(B1+1, 11)
+	k := (lo + D/2) / D
+	VD := lucasV(P, D)
+	Vk := lucasV(VD, k)
+	Vkm1 := VD // V_-D = V_D
+	if k > 0 {
+		Vkm1 = lucasV(VD, k-1)
+	}
+	step := func() {
+		next := new(big.Int).Mul(Vk, VD)
+		next.Sub(next, Vkm1)
+		Vkm1, Vk = Vk, next.Mod(next, N)
+		k++
+	}
+
+	// Step 3: multiply V_kD - V_j for each prime
+	// q = kD +- j, skipping the second of a pair
+	acc := big.NewInt(1)
+	z := new(big.Int)
+	var done [D / 2]bool
+	n := 0
+	var err error
+	Sieve(lo, B2+1, func(q uint64) bool {
+		for k < (q+D/2)/D {
+			step()
+			done = [D / 2]bool{}
+		}
+		j := q - k*D
+		if q < k*D {
+			j = k*D - q
+		}
+		if done[j] {
+			return true
+		}
+		done[j] = true
+		acc.Mul(acc, z.Sub(Vk, baby[j]))
+		acc.Mod(acc, N)
+		if n++; n%256 == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+			g.GCD(nil, nil, acc, N)
+		}
+		return g.Cmp(one) == 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g.GCD(nil, nil, acc, N), nil
+}
+
+// nontrivial returns g if 1 < g < N and nil otherwise.
+func nontrivial(g, N *big.Int) *big.Int {
+	if g == nil || g.Cmp(one) <= 0 || g.Cmp(N) >= 0 {
+		return nil
+	}
+	return g
+}
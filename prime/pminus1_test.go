@@ -0,0 +1,70 @@
+package prime
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// primes with p-1 and p+1 both divisible by a prime above 2^30
+var (
+	rough64, _ = new(big.Int).SetString("13879753585215528623", 10)
+	rough62, _ = new(big.Int).SetString("3589656427150369607", 10)
+)
+
+func TestPMinus1(t *testing.T) {
+	mul := func(p string, q *big.Int) *big.Int {
+		N, _ := new(big.Int).SetString(p, 10)
+		return N.Mul(N, q)
+	}
+	cases := []struct {
+		N      *big.Int
+		B1, B2 uint64
+		want   *big.Int
+	}{
+		{big.NewInt(3), 1000, 100000, nil},
+		{big.NewInt(1 << 20), 1000, 100000, big.NewInt(2)},
+		// p-1 = 2 * ... * 877 with every prime power below 1000
+		{mul("66645491164941246707", rough64), 1000, 1000, big.NewInt(0)},
+		// p-1 = 2 * ... * 941 * 99991
+		{mul("27315479279375759243", rough64), 1000, 100000, big.NewInt(0)},
+		{mul("27315479279375759243", rough64), 1000, 50000, nil},
+		{new(big.Int).Mul(rough62, rough64), 1000, 100000, nil},
+		// p-1 = 2^2 * 3 * 5 * 7 with 7 > B1, which stage 2 skips
+		{mul("421", rough64), 5, 1000, big.NewInt(0)},
+	}
+	for _, c := range cases {
+		d := PMinus1(c.N, c.B1, c.B2)
+		switch {
+		case c.want == nil:
+			assert.Nil(t, d, fmt.Sprintf("N=%d", c.N))
+		case c.want.Sign() == 0:
+			// the factor other than rough64
+			p := new(big.Int).Quo(c.N, rough64)
+			assert.Equal(t, p, d, fmt.Sprintf("N=%d", c.N))
+		default:
+			assert.Equal(t, c.want, d, fmt.Sprintf("N=%d", c.N))
+		}
+	}
+}
+
+func TestPMinus1Context(t *testing.T) {
+	N := new(big.Int).Mul(rough62, rough64)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// stopped in stage 1, and in stage 2 after a short stage 1
+	for _, B := range [][2]uint64{{1e9, 1e11}, {10, 1e9}} {
+		d, err := PMinus1Context(ctx, N, B[0], B[1])
+		assert.Equal(t, context.Canceled, err, fmt.Sprintf("B1=%d, B2=%d", B[0], B[1]))
+		assert.Nil(t, d)
+		d, err = PPlus1Context(ctx, N, B[0], B[1])
+		assert.Equal(t, context.Canceled, err, fmt.Sprintf("B1=%d, B2=%d", B[0], B[1]))
+		assert.Nil(t, d)
+	}
+	d, err := PMinus1Context(context.Background(), big.NewInt(1<<20), 1000, 100000)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(2), d)
+}
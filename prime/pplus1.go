@@ -0,0 +1,73 @@
+package prime
+
+import (
+	"context"
+	"math/big"
+)
+
+// PPlus1 looks for a factor of N with Williams' p+1 method.
+// With V the Lucas sequence for P and Q = 1 it finds a prime
+// p dividing N when p - (D/p) is a product of prime powers at
+// most B1 and at most one more prime in (B1, B2], where
+// D = P^2 - 4. It tries P = 2/7, for which (D/p) = -1 exactly
+// when p = 2 mod 3, and then P = 6/5, for which it is when
+// p = 3 mod 4. Otherwise it works like PMinus1. It returns nil
+// if no factor was found, or if every prime factor of N was
+// found at once.
+//
+// See Williams, "A p+1 method of factoring" (1982).
+func PPlus1(N *big.Int, B1, B2 uint64) *big.Int {
+	d, _ := PPlus1Context(context.Background(), N, B1, B2)
+	return d
+}
+
+// PPlus1Context is PPlus1 but checks ctx during both
+// stages and stops with ctx.Err() once it is done.
+func PPlus1Context(ctx context.Context, N *big.Int, B1, B2 uint64) (*big.Int, error) {
+	// Step 0: parse input / easy cases
+	if N.Cmp(big.NewInt(4)) < 0 {
+		return nil, nil
+	}
+	for _, p := range []int64{2, 3, 5, 7} {
+		if d := big.NewInt(p); new(big.Int).Mod(N, d).Sign() == 0 {
+			return nontrivial(d, N), nil
+		}
+	}
+
+	// V_n(V_m(P)) = V_nm(P) so raising to a power
+	// is replacing P by a term of its sequence
+	D := new(big.Int)
+	pow := func(V, k *big.Int) {
+		D.Mul(V, V)
+		D.Sub(D, big.NewInt(4))
+		_, Vk, _ := lucasSequence(V, one, D, k, N)
+		V.Set(Vk)
+	}
+	for _, seed := range [][2]int64{{2, 7}, {6, 5}} {
+		// Step 1: V = V_E(P) where E is the product
+		// of the prime powers at most B1
+		V := big.NewInt(seed[1])
+		V.ModInverse(V, N)
+		V.Mul(V, big.NewInt(seed[0]))
+		V.Mod(V, N)
+		g, err := smoothStage1(ctx, N, V, B1, two, pow)
+		if err != nil {
+			return nil, err
+		}
+		if g != nil {
+			if d := nontrivial(g, N); d != nil {
+				return d, nil
+			}
+			continue
+		}
+
+		// Step 2: one more prime in (B1, B2]
+		if g, err = lucasStage2(ctx, N, V, B1, B2); err != nil {
+			return nil, err
+		}
+		if d := nontrivial(g, N); d != nil {
+			return d, nil
+		}
+	}
+	return nil, nil
+}
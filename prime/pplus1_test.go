@@ -0,0 +1,43 @@
+package prime
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPPlus1(t *testing.T) {
+	mul := func(p string, q *big.Int) *big.Int {
+		N, _ := new(big.Int).SetString(p, 10)
+		return N.Mul(N, q)
+	}
+	cases := []struct {
+		N      *big.Int
+		B1, B2 uint64
+		want   *big.Int
+	}{
+		{big.NewInt(3), 1000, 100000, nil},
+		{big.NewInt(7 * 65537), 1000, 100000, big.NewInt(7)},
+		// p = 2 mod 3 and p+1 = 6 * ... * 719 with every prime power below 1000
+		{mul("1219411870207117781", rough64), 1000, 1000, big.NewInt(0)},
+		// p = 7 mod 12 so only the second seed works and p+1 = 4 * ... * 983 * 99989
+		{mul("164189885272941003907", rough64), 1000, 100000, big.NewInt(0)},
+		{mul("164189885272941003907", rough64), 1000, 50000, nil},
+		{new(big.Int).Mul(rough62, rough64), 1000, 100000, nil},
+	}
+	for _, c := range cases {
+		d := PPlus1(c.N, c.B1, c.B2)
+		switch {
+		case c.want == nil:
+			assert.Nil(t, d, fmt.Sprintf("N=%d", c.N))
+		case c.want.Sign() == 0:
+			// the factor other than rough64
+			p := new(big.Int).Quo(c.N, rough64)
+			assert.Equal(t, p, d, fmt.Sprintf("N=%d", c.N))
+		default:
+			assert.Equal(t, c.want, d, fmt.Sprintf("N=%d", c.N))
+		}
+	}
+}
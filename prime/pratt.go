@@ -0,0 +1,117 @@
+package prime
+
+import (
+	"math/big"
+)
+
+// Pratt is a Pratt certificate for the primality of N.
+// It consists of a generator G of the multiplicative
+// group mod N together with the factorization of N-1,
+// where every prime factor carries its own certificate.
+//
+// See https://en.wikipedia.org/wiki/Primality_certificate#Pratt_certificates
+type Pratt struct {
+	N       *big.Int
+	G       *big.Int
+	Factors []PrattFactor
+}
+
+// PrattFactor is a prime power P^E dividing N-1
+// along with a certificate that P is prime.
+type PrattFactor struct {
+	P    *big.Int
+	E    uint64
+	Cert *Pratt
+}
+
+// PrattCertificate returns a Pratt certificate for N
+// or nil if N is not prime. The factorization of N-1
+// is found by trial division so this is only practical
+// when N-1 has no large prime factors.
+func PrattCertificate(N *big.Int) *Pratt {
+	// Step 0: parse input / easy cases
+	if N.Cmp(two) < 0 {
+		return nil
+	}
+	if N.Cmp(two) == 0 {
+		return &Pratt{N: new(big.Int).Set(two), G: big.NewInt(1)}
+	}
+	if BPSW(N) == IsComposite {
+		return nil
+	}
+
+	// Step 1: factor N-1 and certify every prime factor
+	nm1 := new(big.Int).Sub(N, one)
+	c := &Pratt{N: new(big.Int).Set(N)}
+	for _, pe := range factorProof(new(big.Int).Set(nm1)) {
+		cert := PrattCertificate(pe.P)
+		if cert == nil {
+			return nil
+		}
+		c.Factors = append(c.Factors, PrattFactor{P: pe.P, E: pe.E, Cert: cert})
+	}
+
+	// Step 2: search for a generator, i.e. g with
+	// g^(N-1) = 1 and g^((N-1)/q) != 1 for all q | N-1
+	z := new(big.Int)
+	for g := big.NewInt(2); g.Cmp(N) == -1; g.Add(g, one) {
+		if prattWitness(N, g, c.Factors, z) {
+			c.G = g
+			return c
+		}
+	}
+	return nil
+}
+
+// VerifyPratt checks that c is a valid Pratt certificate.
+// Every claim in c is checked, including the generator
+// and the certificates of the factors of N-1.
+func VerifyPratt(c *Pratt) bool {
+	// Step 0: parse input / easy cases
+	if c == nil || c.N == nil || c.N.Cmp(two) < 0 {
+		return false
+	}
+	if c.N.Cmp(two) == 0 {
+		return true
+	}
+	if c.G == nil || c.G.Sign() <= 0 || c.G.Cmp(c.N) != -1 {
+		return false
+	}
+
+	// Step 1: the factors should multiply to N-1
+	// and each one should be certified prime
+	prod := big.NewInt(1)
+	z := new(big.Int)
+	for _, f := range c.Factors {
+		if f.P == nil || f.E == 0 || f.Cert == nil || f.Cert.N == nil {
+			return false
+		}
+		// P | N-1 so P < N and the recursion terminates
+		if f.Cert.N.Cmp(f.P) != 0 || f.P.Cmp(c.N) != -1 || !VerifyPratt(f.Cert) {
+			return false
+		}
+		prod.Mul(prod, z.Exp(f.P, new(big.Int).SetUint64(f.E), nil))
+	}
+	if prod.Cmp(z.Sub(c.N, one)) != 0 {
+		return false
+	}
+
+	// Step 2: G should have order exactly N-1
+	return prattWitness(c.N, c.G, c.Factors, z)
+}
+
+// returns true if g has order N-1 mod N
+// given the factorization F of N-1.
+func prattWitness(N, g *big.Int, F []PrattFactor, z *big.Int) bool {
+	nm1 := new(big.Int).Sub(N, one)
+	if z.Exp(g, nm1, N).Cmp(one) != 0 {
+		return false
+	}
+	q := new(big.Int)
+	for _, f := range F {
+		if z.Exp(g, q.Quo(nm1, f.P), N).Cmp(one) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package prime
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrattCertificate(t *testing.T) {
+	cases := []struct {
+		in   *big.Int
+		want bool
+	}{
+		{big.NewInt(1), false},
+		{big.NewInt(2), true},
+		{big.NewInt(3), true},
+		{big.NewInt(4), false},
+		{big.NewInt(561), false}, // carmichael number
+		{big.NewInt(1021), true},
+		{big.NewInt(1709), true},
+		{big.NewInt(2047), false},
+		{big.NewInt(170003), true},
+		{big.NewInt(1700021), true},
+		{big.NewInt(2147483647), true},
+	}
+	for _, c := range cases {
+		cert := PrattCertificate(c.in)
+		assert.Equal(t, c.want, cert != nil, fmt.Sprintf("in=%d", c.in))
+		if cert != nil {
+			assert.True(t, VerifyPratt(cert), fmt.Sprintf("in=%d", c.in))
+		}
+	}
+	for i := 0; i < 20; i++ {
+		p := RandPrime(32)
+		require.True(t, VerifyPratt(PrattCertificate(p)), fmt.Sprintf("p=%d", p))
+	}
+}
+
+func TestVerifyPratt(t *testing.T) {
+	cert := PrattCertificate(big.NewInt(1709))
+	require.True(t, VerifyPratt(cert))
+	// 1 has order 1, not 1708 = 2^2 * 7 * 61
+	bad := *cert
+	bad.G = big.NewInt(1)
+	assert.False(t, VerifyPratt(&bad))
+	bad = *cert
+	bad.N = big.NewInt(1711)
+	assert.False(t, VerifyPratt(&bad))
+	bad = *cert
+	bad.Factors = cert.Factors[1:]
+	assert.False(t, VerifyPratt(&bad))
+	assert.False(t, VerifyPratt(nil))
+}
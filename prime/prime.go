@@ -6,7 +6,11 @@ computational number theory in another language.
 package prime
 
 import (
+	"context"
 	"crypto/rand"
+	"errors"
+	"io"
+	"math"
 	"math/big"
 	"sort"
 )
@@ -18,18 +22,78 @@ var (
 )
 
 func randBig(bits int) *big.Int {
+	N, err := randBigFrom(rand.Reader, bits)
+	if err != nil {
+		panic(err)
+	}
+	return N
+}
+
+// randBigFrom returns a random number with
+// exactly bits bits read from random.
+func randBigFrom(random io.Reader, bits int) (*big.Int, error) {
 	if bits <= 0 {
-		return new(big.Int)
+		return new(big.Int), nil
+	}
+	bytes := make([]byte, (bits+7)/8)
+	if _, err := io.ReadFull(random, bytes); err != nil {
+		return nil, err
+	}
+	// clear the extra bits of the first byte
+	bytes[0] &= byte(0xff >> (8*len(bytes) - bits))
+	return new(big.Int).SetBit(new(big.Int).SetBytes(bytes), bits-1, 1), nil
+}
+
+// randBelow returns a uniformly random
+// number in [0, n) read from random.
+func randBelow(random io.Reader, n *big.Int) (*big.Int, error) {
+	bits := new(big.Int).Sub(n, one).BitLen()
+	bytes := make([]byte, (bits+7)/8)
+	x := new(big.Int)
+	for {
+		if _, err := io.ReadFull(random, bytes); err != nil {
+			return nil, err
+		}
+		if len(bytes) > 0 {
+			bytes[0] &= byte(0xff >> (8*len(bytes) - bits))
+		}
+		if x.SetBytes(bytes).Cmp(n) < 0 {
+			return x, nil
+		}
 	}
-	bytes := make([]byte, bits/8)
-	rand.Read(bytes)
-	return new(big.Int).SetBit(new(big.Int).SetBytes(bytes), bits-1, 1)
 }
 
 // RandPrime returns a random prime
 // of a given bit size. For small bits
 // it just gives something close.
 func RandPrime(bits int) (p *big.Int) {
+	p, err := Prime(rand.Reader, bits)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Prime is RandPrime with the randomness read
+// from random. It has the same signature as
+// crypto/rand.Prime and the same bytes from
+// random always give the same prime.
+func Prime(random io.Reader, bits int) (*big.Int, error) {
+	return PrimeContext(context.Background(), random, bits)
+}
+
+// RandPrimeContext is RandPrime but stops
+// with ctx.Err() once ctx is done.
+func RandPrimeContext(ctx context.Context, bits int) (*big.Int, error) {
+	return PrimeContext(ctx, rand.Reader, bits)
+}
+
+// PrimeContext is Prime but stops
+// with ctx.Err() once ctx is done.
+func PrimeContext(ctx context.Context, random io.Reader, bits int) (*big.Int, error) {
+	if bits < 2 {
+		return nil, errors.New("prime: prime size must be at least 2-bit")
+	}
 	if bits <= 10 {
 		start := sort.Search(len(primes10), func(i int) bool {
 			return big.NewInt(int64(primes10[i])).BitLen() >= bits
@@ -39,14 +103,23 @@ func RandPrime(bits int) (p *big.Int) {
 			return big.NewInt(int64(slice[i])).BitLen() > bits
 		})
 		set := slice[:end]
-		n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
-		return big.NewInt(int64(set[int(n.Int64())]))
+		n, err := randBelow(random, big.NewInt(int64(len(set))))
+		if err != nil {
+			return nil, err
+		}
+		return big.NewInt(int64(set[int(n.Int64())])), nil
 	}
 	for {
-		N := randBig(bits)
-		p := NextPrime(N)
-		if p.BitLen() == bits || bits < 5 {
-			return p
+		N, err := randBigFrom(random, bits)
+		if err != nil {
+			return nil, err
+		}
+		p, err := NextPrimeContext(ctx, N)
+		if err != nil {
+			return nil, err
+		}
+		if p.BitLen() == bits {
+			return p, nil
 		}
 	}
 }
@@ -55,8 +128,15 @@ func RandPrime(bits int) (p *big.Int) {
 // high probability that p is the next prime
 // occurring after N.
 func NextPrime(N *big.Int) (p *big.Int) {
+	p, _ = NextPrimeContext(context.Background(), N)
+	return
+}
+
+// NextPrimeContext is NextPrime but checks ctx between
+// candidates and stops with ctx.Err() once it is done.
+func NextPrimeContext(ctx context.Context, N *big.Int) (p *big.Int, err error) {
 	if N.Sign() <= 0 {
-		return big.NewInt(2)
+		return big.NewInt(2), nil
 	}
 	if N.BitLen() <= 10 {
 		n := uint16(N.Int64())
@@ -64,17 +144,396 @@ func NextPrime(N *big.Int) (p *big.Int) {
 			return primes10[i] >= n
 		})
 		if i < len(primes10) {
-			return big.NewInt(int64(primes10[i]))
+			return big.NewInt(int64(primes10[i])), nil
+		}
+	}
+	return firstPrimeIn(ctx, N, nil, nil)
+}
+
+// PrevPrime returns the largest p <= N with high
+// probability of being prime, or nil if N < 2.
+// Below 2^64 p is certainly prime.
+func PrevPrime(N *big.Int) *big.Int {
+	p, _ := PrevPrimeContext(context.Background(), N)
+	return p
+}
+
+// PrevPrimeContext is PrevPrime but checks ctx between
+// candidates and stops with ctx.Err() once it is done.
+func PrevPrimeContext(ctx context.Context, N *big.Int) (p *big.Int, err error) {
+	if N.Cmp(two) < 0 {
+		return nil, nil
+	}
+	if N.IsUint64() {
+		n := N.Uint64()
+		for !isPrime64(n) {
+			n--
 		}
+		return new(big.Int).SetUint64(n), nil
+	}
+	// sieve down to 2^64 and then start over below it
+	maxUint64 := new(big.Int).SetUint64(math.MaxUint64)
+	err = sieveWindows(ctx, N, maxUint64, true, nil, func(q *big.Int) bool {
+		p = new(big.Int).Set(q)
+		return false
+	})
+	if err != nil || p != nil {
+		return
 	}
-	m := len(diffs210)
-	i := int(new(big.Int).Mod(N, big.NewInt(int64(m))).Int64())
-	p = new(big.Int).Set(N)
+	return PrevPrimeContext(ctx, maxUint64)
+}
+
+// NearestPrime returns the number closest to N with high
+// probability of being prime, the smaller one if there are two.
+func NearestPrime(N *big.Int) *big.Int {
+	p, _ := NearestPrimeContext(context.Background(), N)
+	return p
+}
+
+// NearestPrimeContext is NearestPrime but checks ctx between
+// candidates and stops with ctx.Err() once it is done.
+func NearestPrimeContext(ctx context.Context, N *big.Int) (p *big.Int, err error) {
+	if N.Cmp(two) <= 0 {
+		return big.NewInt(2), nil
+	}
+	// Step 1: small N, test N, N-1, N+1, N-2, ...
+	// the largest prime gap below 2^64 is 1550
+	if N.IsUint64() && N.Uint64() <= 1<<63 {
+		n := N.Uint64()
+		for d := uint64(0); ; d++ {
+			if n-d >= 2 && isPrime64(n-d) {
+				return new(big.Int).SetUint64(n - d), nil
+			}
+			if isPrime64(n + d) {
+				return new(big.Int).SetUint64(n + d), nil
+			}
+		}
+	}
+	// Step 2: find the next prime and then look below
+	// N only as far as it is from N
+	if p, err = NextPrimeContext(ctx, N); err != nil {
+		return nil, err
+	}
+	d := new(big.Int).Sub(p, N)
+	d.Sub(N, d)
+	err = sieveWindows(ctx, N, d.Sub(d, one), true, nil, func(q *big.Int) bool {
+		p = new(big.Int).Set(q)
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+const (
+	// the number of odd candidates sieved at a time
+	sieveWindow = 1 << 11
+	// the number of primes from primes16 they are sieved by,
+	// all primes up to 17863
+	sievePrimes = 1 << 11
+)
+
+// firstPrimeIn returns the first probable prime p with
+// lo <= p < hi, or nil if there is none or stop returns
+// true before one is found, see sieveWindows.
+func firstPrimeIn(ctx context.Context, lo, hi *big.Int, stop func() bool) (p *big.Int, err error) {
+	err = sieveWindows(ctx, lo, hi, false, stop, func(q *big.Int) bool {
+		p = new(big.Int).Set(q)
+		return false
+	})
+	return
+}
+
+// sieveWindows calls yield on each probable prime p from start
+// towards end, going down if down is set, until yield returns
+// false, stop returns true or ctx is done. So p is in [start, end)
+// going up and in (end, start] going down. A nil end or stop
+// means there is no bound or no stopping. It sieves windows of odd
+// candidates by small primes and only runs the Miller-Rabin and
+// Lucas steps of BPSW on the survivors. The candidates must stay
+// above the primes in primes10 and yield must not keep or change p.
+func sieveWindows(ctx context.Context, start, end *big.Int, down bool, stop func() bool, yield func(p *big.Int) bool) error {
+	base := new(big.Int).Set(start)
+	if base.Bit(0) == 0 {
+		if down {
+			base.Sub(base, one)
+		} else {
+			base.Add(base, one)
+		}
+	}
+	// a candidate can only be one of the sieving
+	// primes itself if base is that small
+	small := base.Cmp(big.NewInt(int64(primes16[sievePrimes-1]))) <= 0
+	res := residues(base, sievePrimes)
+	composite := make([]bool, sieveWindow)
+	p := new(big.Int)
 	for {
-		if BPSW(p) != IsComposite {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Step 1: cross out candidates base + 2k divisible by an
+		// odd prime r, which is when k = -base/2 mod r, or
+		// base - 2k when k = base/2 mod r
+		clear(composite)
+		for i, r := range primes16[1:sievePrimes] {
+			r := uint64(r)
+			k := res[i+1]
+			if !down {
+				k = (r - k) % r
+			}
+			k = k * ((r + 1) / 2) % r
+			if small && !down && base.Uint64()+2*k == r {
+				k += r
+			}
+			for ; k < sieveWindow; k += r {
+				composite[k] = true
+			}
+		}
+
+		// Step 2: test the survivors
+		for k, c := range composite {
+			if c {
+				continue
+			}
+			p.SetUint64(uint64(2 * k))
+			if down {
+				p.Sub(base, p)
+				if end != nil && p.Cmp(end) <= 0 {
+					return nil
+				}
+			} else {
+				p.Add(base, p)
+				if end != nil && p.Cmp(end) >= 0 {
+					return nil
+				}
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if stop != nil && stop() {
+				return nil
+			}
+			if StrongMillerRabin(p, 2) != IsComposite && StrongLucasSelfridge(p) != IsComposite {
+				if !yield(p) {
+					return nil
+				}
+			}
+		}
+
+		// Step 3: move to the next window
+		for i, r := range primes16[:sievePrimes] {
+			r := uint64(r)
+			if down {
+				res[i] = (res[i] + r - 2*sieveWindow%r) % r
+			} else {
+				res[i] = (res[i] + 2*sieveWindow) % r
+			}
+		}
+		if down {
+			base.Sub(base, big.NewInt(2*sieveWindow))
+			if end != nil && base.Cmp(end) <= 0 {
+				return nil
+			}
+		} else {
+			base.Add(base, big.NewInt(2*sieveWindow))
+			if end != nil && base.Cmp(end) >= 0 {
+				return nil
+			}
+		}
+		small = false
+	}
+}
+
+// residues returns N mod r for the first n primes r in primes16.
+func residues(N *big.Int, n int) []uint64 {
+	res := make([]uint64, n)
+	z := new(big.Int)
+	P := new(big.Int)
+	// reduce mod products of 4 primes at a time
+	// since each product fits in a single word
+	for i := 0; i < n; i += 4 {
+		group := primes16[i:min(i+4, n)]
+		prod := uint64(1)
+		for _, p := range group {
+			prod *= uint64(p)
+		}
+		rem := z.Mod(N, P.SetUint64(prod)).Uint64()
+		for j, p := range group {
+			res[i+j] = rem % uint64(p)
+		}
+	}
+	return res
+}
+
+// RandSafePrime returns a random safe prime p
+// of a given bit size, so (p-1)/2 is also prime.
+// It sieves candidates q so that neither q nor
+// 2q+1 has a small factor before testing them.
+func RandSafePrime(bits int) (p *big.Int) {
+	p, err := SafePrime(rand.Reader, bits)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// SafePrime is RandSafePrime with the
+// randomness read from random.
+func SafePrime(random io.Reader, bits int) (p *big.Int, err error) {
+	return SafePrimeContext(context.Background(), random, bits)
+}
+
+// SafePrimeContext is SafePrime but stops
+// with ctx.Err() once ctx is done.
+func SafePrimeContext(ctx context.Context, random io.Reader, bits int) (p *big.Int, err error) {
+	if bits < 3 {
+		return nil, errors.New("prime: safe prime size must be at least 3-bit")
+	}
+	if bits <= 32 {
+		for {
+			q, err := PrimeContext(ctx, random, bits-1)
+			if err != nil {
+				return nil, err
+			}
+			p = new(big.Int).Lsh(q, 1)
+			p.Add(p, one)
+			if BPSW(p) != IsComposite {
+				return p, nil
+			}
+		}
+	}
+	const window = 1 << 12
+	sieve := make([]bool, window)
+	q := new(big.Int)
+	for {
+		// Step 1: pick a random odd q with bits-1 bits
+		q0, err := randBigFrom(random, bits-1)
+		if err != nil {
+			return nil, err
+		}
+		q0.SetBit(q0, 0, 1)
+		res := residues(q0, len(primes16))
+
+		// Step 2: cross out offsets i where r divides
+		// q = q0 + i or p = 2(q0 + i) + 1, which is when
+		// q0 + i = 0 or (r-1)/2 mod r
+		for i := range sieve {
+			sieve[i] = false
+		}
+		for j, r := range primes16[1:] {
+			r := uint64(r)
+			for _, bad := range []uint64{0, (r - 1) / 2} {
+				for i := (bad + r - res[j+1]) % r; i < window; i += r {
+					sieve[i] = true
+				}
+			}
+		}
+
+		// Step 3: test the survivors
+		for i := 0; i < window; i += 2 {
+			if sieve[i] {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			q.Add(q0, big.NewInt(int64(i)))
+			if q.BitLen() != bits-1 {
+				break
+			}
+			p = new(big.Int).Lsh(q, 1)
+			p.Add(p, one)
+			// cheap tests on both first
+			if StrongMillerRabin(q, 2) == IsComposite || StrongMillerRabin(p, 2) == IsComposite {
+				continue
+			}
+			if BPSW(q) != IsComposite && BPSW(p) != IsComposite {
+				return p, nil
+			}
+		}
+	}
+}
+
+// RandStrongPrime returns a random strong prime p
+// of a given bit size. That is p-1 has a large prime
+// factor r, p+1 has a large prime factor s and r-1
+// has a large prime factor t.
+// It uses Gordon's algorithm, see
+// Menezes et al. Handbook of Applied Cryptography, algorithm 4.53.
+func RandStrongPrime(bits int) (p *big.Int) {
+	p, err := StrongPrime(rand.Reader, bits)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// StrongPrime is RandStrongPrime with the
+// randomness read from random.
+func StrongPrime(random io.Reader, bits int) (p *big.Int, err error) {
+	p, _, _, _, err = gordon(random, bits)
+	return
+}
+
+// gordon returns a strong prime p along with the
+// prime factors r | p-1, s | p+1 and t | r-1.
+func gordon(random io.Reader, bits int) (p, r, s, t *big.Int, err error) {
+	if bits < 32 {
+		return nil, nil, nil, nil, errors.New("prime: strong prime size must be at least 32-bit")
+	}
+	lo := new(big.Int).Lsh(one, uint(bits-1))
+	hi := new(big.Int).Lsh(one, uint(bits))
+	for {
+		// Step 1: generate large primes s and t
+		if s, err = Prime(random, bits/2-4); err != nil {
 			return
 		}
-		p.Add(p, big.NewInt(int64(diffs210[i])))
-		i = (i + diffs210[i]) % m
+		if t, err = Prime(random, bits/2-12); err != nil {
+			return
+		}
+
+		// Step 2: find the first prime r = 2it + 1
+		r = new(big.Int).Lsh(t, 1)
+		r.Add(r, one)
+		for BPSW(r) == IsComposite {
+			r.Add(r, t)
+			r.Add(r, t)
+		}
+
+		// Step 3: p0 = 2(s^(r-2) mod r)s - 1 so that
+		// p0 = 1 mod r and p0 = -1 mod s
+		p0 := new(big.Int).Exp(s, new(big.Int).Sub(r, two), r)
+		p0.Mul(p0, s)
+		p0.Lsh(p0, 1)
+		p0.Sub(p0, one)
+
+		// Step 4: find the first prime p = p0 + 2jrs
+		// starting from a random j with p in [lo, hi)
+		rs2 := new(big.Int).Mul(r, s)
+		rs2.Lsh(rs2, 1)
+		jmin := new(big.Int).Sub(lo, p0)
+		jmin.Add(jmin, rs2)
+		jmin.Sub(jmin, one)
+		jmin.Quo(jmin, rs2)
+		jmax := new(big.Int).Sub(hi, p0)
+		jmax.Quo(jmax, rs2)
+		if jmax.Cmp(jmin) != 1 {
+			continue
+		}
+		j, err := randBelow(random, jmax.Sub(jmax, jmin))
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		j.Add(j, jmin)
+		p = new(big.Int).Mul(j, rs2)
+		p.Add(p, p0)
+		for p.Cmp(hi) == -1 {
+			if BPSW(p) != IsComposite {
+				return p, r, s, t, nil
+			}
+			p.Add(p, rs2)
+		}
 	}
 }
@@ -1,9 +1,13 @@
 package prime
 
 import (
+	"context"
+	"crypto/rand"
 	"fmt"
 	"math/big"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -17,6 +21,12 @@ func TestRandBig(t *testing.T) {
 		require.Equal(t, x.BitLen(), i)
 		require.True(t, x.Sign() >= 0)
 	}
+	// the bits below the top one are random too
+	var or big.Int
+	for i := 0; i < 100; i++ {
+		or.Or(&or, randBig(682))
+	}
+	require.Equal(t, uint(1), or.Bit(680))
 }
 
 func TestRandPrime(t *testing.T) {
@@ -26,6 +36,34 @@ func TestRandPrime(t *testing.T) {
 	}
 }
 
+func TestRandSafePrime(t *testing.T) {
+	for _, bits := range []int{3, 4, 10, 32, 33, 64, 128, 256} {
+		p := RandSafePrime(bits)
+		q := new(big.Int).Rsh(p, 1)
+		require.Equal(t, bits, p.BitLen())
+		require.True(t, p.ProbablyPrime(20), fmt.Sprintf("p=%d", p))
+		require.True(t, q.ProbablyPrime(20), fmt.Sprintf("q=%d", q))
+	}
+}
+
+func TestRandStrongPrime(t *testing.T) {
+	z := new(big.Int)
+	for _, bits := range []int{32, 33, 64, 128, 512} {
+		p, r, s, q, err := gordon(rand.Reader, bits)
+		require.NoError(t, err)
+		require.Equal(t, bits, p.BitLen())
+		require.True(t, p.ProbablyPrime(20), fmt.Sprintf("p=%d", p))
+		for _, x := range []*big.Int{r, s, q} {
+			require.True(t, x.ProbablyPrime(20), fmt.Sprintf("x=%d", x))
+			require.True(t, x.BitLen() >= bits/2-12, fmt.Sprintf("x=%d", x))
+		}
+		require.Zero(t, z.Mod(z.Sub(p, one), r).Sign())
+		require.Zero(t, z.Mod(z.Add(p, one), s).Sign())
+		require.Zero(t, z.Mod(z.Sub(r, one), q).Sign())
+	}
+	require.Equal(t, 256, RandStrongPrime(256).BitLen())
+}
+
 func TestTrailingZeroBits(t *testing.T) {
 	cases := []struct {
 		in   *big.Int
@@ -195,6 +233,102 @@ func TestNextPrime(t *testing.T) {
 	}
 }
 
+func TestPrevPrime(t *testing.T) {
+	M64 := new(big.Int).Lsh(one, 64)
+	cases := []struct {
+		in, want *big.Int
+	}{
+		{big.NewInt(-5), nil},
+		{big.NewInt(1), nil},
+		{big.NewInt(2), big.NewInt(2)},
+		{big.NewInt(4), big.NewInt(3)},
+		{big.NewInt(1709), big.NewInt(1709)},
+		{big.NewInt(1710), big.NewInt(1709)},
+		{big.NewInt(1693182318747502), big.NewInt(1693182318746371)},
+		{M64, new(big.Int).Sub(M64, big.NewInt(59))},
+		// the next prime after 2^64 is 2^64 + 13
+		{new(big.Int).Add(M64, big.NewInt(12)), new(big.Int).Sub(M64, big.NewInt(59))},
+		{new(big.Int).Add(M64, big.NewInt(14)), new(big.Int).Add(M64, big.NewInt(13))},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, PrevPrime(c.in), fmt.Sprintf("in=%d", c.in))
+	}
+	for i := 0; i < 10; i++ {
+		N := randBig(200)
+		p := PrevPrime(N)
+		require.NotEqual(t, IsComposite, BPSW(p), fmt.Sprintf("N=%d", N))
+		require.True(t, p.Cmp(N) <= 0)
+		for x := new(big.Int).Add(p, one); x.Cmp(N) <= 0; x.Add(x, one) {
+			require.Equal(t, IsComposite, BPSW(x), fmt.Sprintf("x=%d", x))
+		}
+	}
+	assert.Equal(t, big.NewInt(1709), PrevPrimeProof(big.NewInt(1720)))
+	assert.Nil(t, PrevPrimeProof(big.NewInt(1)))
+}
+
+func TestNearestPrime(t *testing.T) {
+	cases := []struct {
+		in, want int64
+	}{
+		{-5, 2},
+		{0, 2},
+		{3, 3},
+		{4, 3}, // a tie between 3 and 5
+		{6, 5},
+		{8, 7},
+		{10, 11},
+		{1693182318746372 + 565, 1693182318746371},
+		{1693182318746372 + 566, 1693182318747503},
+	}
+	for _, c := range cases {
+		assert.Equal(t, big.NewInt(c.want), NearestPrime(big.NewInt(c.in)), fmt.Sprintf("in=%d", c.in))
+		if c.in < 1<<20 {
+			assert.Equal(t, big.NewInt(c.want), NearestPrimeProof(big.NewInt(c.in)), fmt.Sprintf("in=%d", c.in))
+		}
+	}
+	for i := 0; i < 10; i++ {
+		N := randBig(200)
+		up, down := NextPrime(N), PrevPrime(N)
+		want := up
+		if new(big.Int).Sub(N, down).Cmp(new(big.Int).Sub(up, N)) <= 0 {
+			want = down
+		}
+		assert.Equal(t, want, NearestPrime(N), fmt.Sprintf("N=%d", N))
+	}
+}
+
+func TestFirstPrimeIn(t *testing.T) {
+	// around the sieving primes every candidate must still
+	// agree with BPSW, including the sieving primes themselves
+	for n := int64(1022); n < 20000; n += 37 {
+		want := big.NewInt(n)
+		for BPSW(want) == IsComposite {
+			want.Add(want, one)
+		}
+		p, err := firstPrimeIn(context.Background(), big.NewInt(n), nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, want, p, fmt.Sprintf("n=%d", n))
+	}
+	// no probable primes are skipped for larger numbers
+	lo := new(big.Int).Lsh(one, 200)
+	p, err := firstPrimeIn(context.Background(), lo, nil, nil)
+	require.NoError(t, err)
+	for x := new(big.Int).Set(lo); x.Cmp(p) < 0; x.Add(x, one) {
+		require.Equal(t, IsComposite, BPSW(x), fmt.Sprintf("x=%d", x))
+	}
+	// the gap after 1693182318746371 is 1132
+	lo = big.NewInt(1693182318746372)
+	p, err = firstPrimeIn(context.Background(), lo, big.NewInt(1693182318747503), nil)
+	require.NoError(t, err)
+	assert.Nil(t, p)
+	p, err = firstPrimeIn(context.Background(), lo, big.NewInt(1693182318747504), nil)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(1693182318747503), p)
+	p, err = firstPrimeIn(context.Background(), lo, nil, func() bool { return true })
+	require.NoError(t, err)
+	assert.Nil(t, p)
+}
+
 func TestJacobiSymbol(t *testing.T) {
 	cases := []struct {
 		N, D *big.Int
@@ -239,18 +373,130 @@ func TestSolovayStrassen(t *testing.T) {
 
 func TestFactor(t *testing.T) {
 	x := big.NewInt(2 * 2 * 2 * 2 * 3 * 5 * 5 * 11)
-	F := factor(x)
+	F := Factor(x)
 	require.Len(t, F, 4)
-	for p, e := range F {
-		switch {
-		case p.Int64() == 2:
-			require.Equal(t, e, uint64(4))
-		case p.Int64() == 3:
-			require.Equal(t, e, uint64(1))
-		case p.Int64() == 5:
-			require.Equal(t, e, uint64(2))
-		case p.Int64() == 11:
-			require.Equal(t, e, uint64(1))
+	assert.Equal(t, "2^4 * 3 * 5^2 * 11", F.String())
+	assert.Equal(t, x, F.Value())
+	assert.Equal(t, Factorization{}, Factor(big.NewInt(0)))
+	assert.Equal(t, "2 * 3", Factor(big.NewInt(-6)).String())
+}
+
+func TestFactorRho(t *testing.T) {
+	p40 := big.NewInt(1<<40 - 87)
+	q40 := big.NewInt(1<<40 + 15)
+	cases := []struct {
+		N *big.Int
+		F string
+	}{
+		{big.NewInt(1), "1"},
+		{big.NewInt(65537), "65537"},
+		{big.NewInt(65537 * 65537 * 12), "2^2 * 3 * 65537^2"},
+		{new(big.Int).Mul(p40, q40), "1099511627689 * 1099511627791"},
+		{new(big.Int).Mul(new(big.Int).Mul(p40, p40), big.NewInt(40*2147483647)),
+			"2^3 * 5 * 2147483647 * 1099511627689^2"},
+		// two 64 bit primes are too large for rho
+		{new(big.Int).Mul(rough62, rough64), fmt.Sprintf("%d * %d", rough62, rough64)},
+	}
+	for _, c := range cases {
+		N := new(big.Int).Set(c.N)
+		F := Factor(N)
+		assert.Equal(t, c.N, N, "Factor should not change N")
+		assert.Equal(t, c.F, F.String(), fmt.Sprintf("N=%d", c.N))
+	}
+	// a 64 bit prime times a product of two 40 bit primes
+	N := new(big.Int).Mul(p40, q40)
+	N.Mul(N, new(big.Int).SetUint64(1<<64-59))
+	F := Factor(N)
+	require.Len(t, F, 3)
+	for _, pe := range F {
+		assert.Equal(t, uint64(1), pe.E)
+		assert.NotEqual(t, IsComposite, BPSW(pe.P), fmt.Sprintf("p=%d", pe.P))
+	}
+	assert.Equal(t, N, F.Value())
+}
+
+func TestFactorWithOptions(t *testing.T) {
+	p40 := big.NewInt(1<<40 - 87)
+	// p-1 and p+1 find the first factors, then ECM the other
+	// 40 bit one and the cube of rough64 is split by a cube root
+	smooth, _ := new(big.Int).SetString("27315479279375759243", 10)
+	N := new(big.Int).Mul(smooth, new(big.Int).Exp(rough64, big.NewInt(3), nil))
+	N.Mul(N, new(big.Int).Mul(p40, big.NewInt(1<<40+15)))
+	var msgs []string
+	F, err := FactorWithOptions(context.Background(), N, &FactorOptions{
+		Workers:  1,
+		Progress: func(s string) { msgs = append(msgs, s) },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, N, F.Value())
+	assert.Equal(t, fmt.Sprintf("1099511627689 * 1099511627791 * %d^3 * 27315479279375759243", rough64), F.String())
+	assert.Contains(t, msgs, "p-1 with B1=1000, B2=100000")
+	assert.Contains(t, msgs, "rho for 16384 iterations")
+	assert.Contains(t, msgs, "ECM with B1=2000, B2=200000 on 25 curves for 15 digit factors")
+	assert.Equal(t, Factor(N), F)
+	// nil options are the defaults
+	F, err = FactorWithOptions(context.Background(), big.NewInt(1001), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "7 * 11 * 13", F.String())
+	// ECM alone gives up after its curves
+	N = new(big.Int).Mul(rough62, rough64)
+	_, err = FactorWithOptions(context.Background(), N, &FactorOptions{ECM: true, Curves: 1, Workers: 1})
+	assert.Error(t, err)
+	F, err = FactorWithOptions(context.Background(), new(big.Int).Mul(p40, rough64), &FactorOptions{ECM: true, B1: 2000, Workers: 2})
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("1099511627689 * %d", rough64), F.String())
+	// a composite root is split once
+	pq := new(big.Int).Mul(p40, rough64)
+	msgs = nil
+	F, err = FactorWithOptions(context.Background(), new(big.Int).Exp(pq, big.NewInt(3), nil), &FactorOptions{
+		Workers:  1,
+		Progress: func(s string) { msgs = append(msgs, s) },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("1099511627689^3 * %d^3", rough64), F.String())
+	splits := 0
+	for _, m := range msgs {
+		if strings.HasPrefix(m, "splitting") {
+			splits++
 		}
 	}
+	assert.Equal(t, 1, splits, msgs)
+	// looking for roots of a 2048 bit N stops in time too
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = FactorContext(ctx, new(big.Int).Mul(RandPrime(1024), RandPrime(1024)))
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Less(t, time.Since(start), time.Second)
+	// a large B1 is cut short by ctx
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = FactorWithOptions(ctx, new(big.Int).Mul(RandPrime(200), RandPrime(200)), &FactorOptions{B1: 1e12})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestContext(t *testing.T) {
+	done, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := RandPrimeContext(done, 1024)
+	assert.Equal(t, context.Canceled, err)
+	_, err = NextPrimeContext(done, randBig(1024))
+	assert.Equal(t, context.Canceled, err)
+	_, err = SafePrimeContext(done, rand.Reader, 1024)
+	assert.Equal(t, context.Canceled, err)
+	// trial division up to sqrt(N) would never finish
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = NextPrimeProofContext(ctx, randBig(200))
+	assert.Equal(t, context.DeadlineExceeded, err)
+	N := new(big.Int).Mul(RandPrime(64), RandPrime(64))
+	_, err = FactorContext(ctx, N)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	// still works if ctx is never done
+	p, err := NextPrimeProofContext(context.Background(), big.NewInt(1700))
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(1709), p)
+	p, err = NextPrimeContext(context.Background(), big.NewInt(1700000))
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(1700021), p)
 }
@@ -1,45 +1,92 @@
 package prime
 
 import (
+	"context"
 	"math/big"
 )
 
 func NextPrimeProof(N *big.Int) *big.Int {
-	p := NextPrime(N)
-	for !SimpleProof(p) {
-		p = NextPrime(p)
+	p, _ := NextPrimeProofContext(context.Background(), N)
+	return p
+}
+
+// NextPrimeProofContext is NextPrimeProof but checks ctx
+// while searching and stops with ctx.Err() once it is done.
+func NextPrimeProofContext(ctx context.Context, N *big.Int) (*big.Int, error) {
+	p, err := NextPrimeContext(ctx, N)
+	for err == nil {
+		var ok bool
+		if ok, err = simpleProofContext(ctx, p); ok {
+			return p, nil
+		}
+		if err == nil {
+			p, err = NextPrimeContext(ctx, p.Add(p, one))
+		}
+	}
+	return nil, err
+}
+
+// PrevPrimeProof is PrevPrime for primes
+// proven by SimpleProof, like NextPrimeProof.
+func PrevPrimeProof(N *big.Int) *big.Int {
+	p := PrevPrime(N)
+	for p != nil && !SimpleProof(p) {
+		p = PrevPrime(p.Sub(p, one))
 	}
 	return p
 }
 
+// NearestPrimeProof is NearestPrime for primes
+// proven by SimpleProof, like NextPrimeProof.
+func NearestPrimeProof(N *big.Int) *big.Int {
+	up := NextPrimeProof(N)
+	down := PrevPrimeProof(N)
+	if down != nil && new(big.Int).Sub(N, down).Cmp(new(big.Int).Sub(up, N)) <= 0 {
+		return down
+	}
+	return up
+}
+
 func SimpleProof(N *big.Int) bool {
+	ok, _ := simpleProofContext(context.Background(), N)
+	return ok
+}
+
+// the number of trial divisions between checks of ctx
+const ctxCheckInterval = 1 << 12
+
+func simpleProofContext(ctx context.Context, N *big.Int) (bool, error) {
 	if SmallPrimeTest(N) == IsPrime {
-		return true
+		return true, nil
 	}
 	z := new(big.Int)
 	if N.Cmp(big.NewInt(int64(primes10[len(primes10)-1]))) < 1 {
-		return false
+		return false, nil
 	}
 	s := new(big.Int)
 	s.Sqrt(N)
 	d := big.NewInt(2)
-	for d.Cmp(s) != 1 {
+	for i := 0; d.Cmp(s) != 1; i++ {
+		if i%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
+		}
 		if z.Mod(N, d); z.Cmp(zero) == 0 {
-			return false
+			return false, nil
 		}
 		d.Add(d, one)
 	}
-	return true
+	return true, nil
 }
 
-func factorProof(N *big.Int) factorization {
-	F := make(factorization)
+func factorProof(N *big.Int) Factorization {
+	F := Factorization{}
 	// Find power of 2 dividing F
-	var e int64
+	var e uint64
 	for ; N.Bit(int(e)) == 0; e++ {
-		count, _ := F[two]
-		F[two] = count + 1
 	}
+	F.add(two, e)
 	N.Rsh(N, uint(e))
 	// Find upper limit
 	s := new(big.Int)
@@ -49,20 +96,19 @@ func factorProof(N *big.Int) factorization {
 	q := new(big.Int)
 	r := new(big.Int)
 	for p.Cmp(s) != 1 && N.Cmp(one) == 1 {
-		for e = 1; ; e++ {
+		for e = 0; ; e++ {
 			q.QuoRem(N, p, r)
-			if r.BitLen() == 0 {
-				N.Set(q)
-				s.Sqrt(N)
-				F[p] = uint64(e)
-			} else {
+			if r.BitLen() != 0 {
 				break
 			}
+			N.Set(q)
+			s.Sqrt(N)
 		}
+		F.add(p, e)
 		p = NextPrimeProof(big.NewInt(0).Add(p, two))
 	}
 	if N.Cmp(one) == 1 {
-		F[N] = 1
+		F.add(N, 1)
 	}
 	return F
 }
@@ -88,13 +134,13 @@ func binarySearchKthRoot(n *big.Int, k int) (a *big.Int) {
 	test := func(a *big.Int) int {
 		return new(big.Int).Exp(a, K, nil).Cmp(n)
 	}
+	// n has b bits so low = 2^((b-1)/k) <= a < 2^(b/k) <= high
+	b := n.BitLen()
+	low := new(big.Int).Lsh(one, uint(max(b-1, 0)/k))
+	high := new(big.Int).Lsh(one, uint((b+k-1)/k))
 	a = new(big.Int)
-	low := big.NewInt(2)
-	if test(low) == 0 {
-		return low
-	}
-	high := new(big.Int).Set(n) // TODO rsh by log_2(n)/k ish
-	for {
+	d := new(big.Int)
+	for d.Sub(high, low).Cmp(one) > 0 {
 		a.Rsh(a.Add(low, high), 1)
 		t := test(a)
 		if t == 0 {
@@ -105,8 +151,9 @@ func binarySearchKthRoot(n *big.Int, k int) (a *big.Int) {
 		} else {
 			low.Set(a)
 		}
-		if a.Sub(high, low).Cmp(one) == 0 {
-			return nil
-		}
 	}
+	if test(low) == 0 {
+		return low
+	}
+	return nil
 }
@@ -56,6 +56,9 @@ func Test_binarySearchKthRoot(t *testing.T) {
 		{"27", big.NewInt(27), 3, big.NewInt(3)},
 		{"125", big.NewInt(125), 3, big.NewInt(5)},
 		{"124", big.NewInt(124), 5, nil},
+		{"3", big.NewInt(3), 2, nil},
+		{"2^200", new(big.Int).Lsh(one, 200), 5, new(big.Int).Lsh(one, 40)},
+		{"(2^61-1)^7", new(big.Int).Exp(big.NewInt(2305843009213693951), big.NewInt(7), nil), 7, big.NewInt(2305843009213693951)},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
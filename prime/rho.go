@@ -0,0 +1,70 @@
+package prime
+
+import (
+	"context"
+	"math/big"
+)
+
+// brentRho looks for a factor of N with Pollard's rho
+// method using Brent's cycle detection and the map
+// x -> x^2 + c. The product of 128 differences is
+// taken before each gcd. It gives up after about limit
+// iterations and returns nil if no factor was found.
+//
+// See Brent, "An improved Monte Carlo factorization algorithm" (1980).
+func brentRho(N *big.Int, c int64, limit int) *big.Int {
+	d, _ := brentRhoContext(context.Background(), N, c, limit)
+	return d
+}
+
+// brentRhoContext is brentRho but checks ctx before
+// each gcd and stops with ctx.Err() once it is done.
+func brentRhoContext(ctx context.Context, N *big.Int, c int64, limit int) (*big.Int, error) {
+	const m = 128
+	C := big.NewInt(c)
+	f := func(x *big.Int) *big.Int {
+		x.Mul(x, x)
+		x.Add(x, C)
+		return x.Mod(x, N)
+	}
+	y := big.NewInt(2)
+	x := new(big.Int)
+	ys := new(big.Int)
+	q := big.NewInt(1)
+	g := big.NewInt(1)
+	d := new(big.Int)
+	for r := 1; g.Cmp(one) == 0; r <<= 1 {
+		if r > limit {
+			return nil, nil
+		}
+		x.Set(y)
+		for i := 0; i < r; i++ {
+			f(y)
+		}
+		for k := 0; k < r && g.Cmp(one) == 0; k += m {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			ys.Set(y)
+			for i := 0; i < min(m, r-k); i++ {
+				f(y)
+				q.Mul(q, d.Abs(d.Sub(x, y)))
+				q.Mod(q, N)
+			}
+			g.GCD(nil, nil, q, N)
+		}
+	}
+	if g.Cmp(N) == 0 {
+		// the batch overshot so redo it one step at a time
+		for {
+			f(ys)
+			if g.GCD(nil, nil, d.Abs(d.Sub(x, ys)), N).Cmp(one) != 0 {
+				break
+			}
+		}
+	}
+	if g.Cmp(N) == 0 {
+		return nil, nil
+	}
+	return g, nil
+}
@@ -0,0 +1,126 @@
+package prime
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// the public exponent used by GenerateRSAKey
+const rsaExponent = 65537
+
+// the smallest modulus GenerateRSAKey makes, which
+// is also the smallest crypto/rsa.GenerateKey allows
+const rsaMinBits = 1024
+
+// GenerateRSAKey returns an RSA private key whose modulus has
+// the given bit size, at least 1024, and is a product of nprimes primes.
+// The primes come from Prime with randomness read from
+// random and each one has its top two
+// bits set and gcd(p-1, e) = 1. Any two of them differ by more
+// than 2^(b-100) when they are b > 100 bits long,
+// see FIPS 186-4, appendix B.3.1.
+func GenerateRSAKey(random io.Reader, bits, nprimes int) (*rsa.PrivateKey, error) {
+	// Step 0: parse input
+	if nprimes < 2 {
+		return nil, errors.New("prime: RSA needs at least 2 primes")
+	}
+	if bits < rsaMinBits {
+		return nil, fmt.Errorf("prime: %d bit RSA keys are insecure, use at least %d", bits, rsaMinBits)
+	}
+	if bits/nprimes < 32 {
+		return nil, fmt.Errorf("prime: %d bits is too small for %d primes", bits, nprimes)
+	}
+	E := big.NewInt(rsaExponent)
+	for {
+		// Step 1: pick primes with sizes adding up to bits
+		primes := make([]*big.Int, nprimes)
+		N := big.NewInt(1)
+		todo := bits
+		for i := range primes {
+			b := todo / (nprimes - i)
+			todo -= b
+			p, err := rsaPrime(random, b, E)
+			if err != nil {
+				return nil, err
+			}
+			primes[i] = p
+			N.Mul(N, primes[i])
+		}
+		// with more than 2 primes the top bits
+		// are not enough to fix the size of N
+		if N.BitLen() != bits || !rsaFarApart(primes) {
+			continue
+		}
+
+		// Step 2: d = e^-1 mod lcm(p_i - 1)
+		L := big.NewInt(1)
+		pm1 := new(big.Int)
+		g := new(big.Int)
+		for _, p := range primes {
+			pm1.Sub(p, one)
+			g.GCD(nil, nil, L, pm1)
+			L.Mul(L, g.Quo(pm1, g))
+		}
+		d := new(big.Int).ModInverse(E, L)
+		if d == nil || d.BitLen() <= bits/2 {
+			continue
+		}
+
+		// Step 3: let crypto/rsa check the key
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: N, E: rsaExponent},
+			D:         d,
+			Primes:    primes,
+		}
+		key.Precompute()
+		if err := key.Validate(); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+}
+
+// rsaPrime returns a prime p with bits bits, the top
+// two bits set and gcd(p-1, E) = 1.
+func rsaPrime(random io.Reader, bits int, E *big.Int) (*big.Int, error) {
+	g := new(big.Int)
+	pm1 := new(big.Int)
+	for {
+		p, err := Prime(random, bits)
+		if err != nil {
+			return nil, err
+		}
+		if p.Bit(bits-2) == 0 {
+			continue
+		}
+		if g.GCD(nil, nil, pm1.Sub(p, one), E).Cmp(one) == 0 {
+			return p, nil
+		}
+	}
+}
+
+// rsaFarApart returns true if the primes pairwise
+// differ by more than 2^(b-100) where b is the size
+// of the smallest one, or are just distinct if b <= 100.
+func rsaFarApart(primes []*big.Int) bool {
+	b := primes[0].BitLen()
+	for _, p := range primes {
+		b = min(b, p.BitLen())
+	}
+	bound := new(big.Int)
+	if b > 100 {
+		bound.Lsh(one, uint(b-100))
+	}
+	d := new(big.Int)
+	for i, p := range primes {
+		for _, q := range primes[:i] {
+			if d.Abs(d.Sub(p, q)).Cmp(bound) != 1 {
+				return false
+			}
+		}
+	}
+	return true
+}
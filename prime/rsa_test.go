@@ -0,0 +1,64 @@
+package prime
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateRSAKey(t *testing.T) {
+	cases := []struct {
+		bits, nprimes int
+	}{
+		{1024, 2},
+		{2048, 2},
+		{2048, 3},
+	}
+	E := big.NewInt(rsaExponent)
+	for _, c := range cases {
+		msg := fmt.Sprintf("bits=%d, nprimes=%d", c.bits, c.nprimes)
+		key, err := GenerateRSAKey(rand.Reader, c.bits, c.nprimes)
+		require.NoError(t, err, msg)
+		assert.Equal(t, c.bits, key.N.BitLen(), msg)
+		require.Len(t, key.Primes, c.nprimes, msg)
+		for _, p := range key.Primes {
+			assert.Equal(t, uint(1), p.Bit(p.BitLen()-2), msg)
+			assert.Equal(t, one, new(big.Int).GCD(nil, nil, new(big.Int).Sub(p, one), E), msg)
+		}
+		assert.True(t, rsaFarApart(key.Primes), msg)
+
+		// round trip through PKCS #1 and PKCS #8
+		k1, err := x509.ParsePKCS1PrivateKey(x509.MarshalPKCS1PrivateKey(key))
+		require.NoError(t, err, msg)
+		assert.True(t, key.Equal(k1), msg)
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		require.NoError(t, err, msg)
+		k8, err := x509.ParsePKCS8PrivateKey(der)
+		require.NoError(t, err, msg)
+		assert.True(t, key.Equal(k8.(*rsa.PrivateKey)), msg)
+	}
+	_, err := GenerateRSAKey(rand.Reader, 2048, 1)
+	assert.Error(t, err)
+	_, err = GenerateRSAKey(rand.Reader, 64, 3)
+	assert.Error(t, err)
+	_, err = GenerateRSAKey(rand.Reader, 512, 2)
+	assert.Error(t, err)
+	_, err = GenerateRSAKey(rand.Reader, 1024, 40)
+	assert.Error(t, err)
+}
+
+func TestRSAFarApart(t *testing.T) {
+	p := new(big.Int).Lsh(one, 511)
+	q := new(big.Int).Add(p, new(big.Int).Lsh(one, 412))
+	assert.False(t, rsaFarApart([]*big.Int{p, q}))
+	q.Add(q, one)
+	assert.True(t, rsaFarApart([]*big.Int{p, q}))
+	assert.False(t, rsaFarApart([]*big.Int{big.NewInt(7), big.NewInt(11), big.NewInt(7)}))
+	assert.True(t, rsaFarApart([]*big.Int{big.NewInt(7), big.NewInt(11), big.NewInt(13)}))
+}
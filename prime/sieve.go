@@ -0,0 +1,130 @@
+package prime
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+const (
+	// the number of odd numbers sieved at a time, so
+	// a segment of the sieve stays in the L2 cache
+	sieveSegment = 1 << 17
+	// the largest sieving prime is below 2^22, and
+	// above 2^44 whatever is left is tested instead
+	sieveBaseBits = 22
+)
+
+var (
+	primes22     []uint32
+	primes22Once sync.Once
+)
+
+// Sieve calls f on each prime p with lo <= p < hi in
+// increasing order until f returns false. It uses a segmented
+// sieve of Eratosthenes on the odd numbers. Above 2^44 the
+// numbers which survive sieving by every prime below 2^22 are
+// checked with a deterministic Miller-Rabin test.
+func Sieve(lo, hi uint64, f func(p uint64) bool) {
+	// Step 0: parse input / easy cases
+	if lo <= 2 && 2 < hi && !f(2) {
+		return
+	}
+	lo |= 1
+	if lo >= hi {
+		return
+	}
+
+	// Step 1: find the sieving primes and the index
+	// of each one's first odd multiple >= max(lo, p^2)
+	limit := sqrt64(hi - 1)
+	exact := limit < 1<<sieveBaseBits
+	base := sievingPrimes(min(limit, 1<<sieveBaseBits-1))
+	next := make([]uint64, len(base))
+	for i, p := range base {
+		p := uint64(p)
+		if p*p >= lo {
+			next[i] = (p*p - lo) / 2
+			continue
+		}
+		off := (p - lo%p) % p
+		if off%2 == 1 {
+			off += p
+		}
+		next[i] = off / 2
+	}
+
+	// Step 2: sieve one segment lo, lo+2, ..., lo+2(n-1) at a time
+	composite := make([]bool, sieveSegment)
+	for {
+		n := min(sieveSegment, (hi-lo+1)/2)
+		seg := composite[:n]
+		clear(seg)
+		for i, p := range base {
+			k := next[i]
+			for ; k < n; k += uint64(p) {
+				seg[k] = true
+			}
+			next[i] = k - n
+		}
+		if lo == 1 {
+			seg[0] = true
+		}
+		for i, c := range seg {
+			if c {
+				continue
+			}
+			p := lo + 2*uint64(i)
+			if !exact && p>>(2*sieveBaseBits) != 0 && !isPrime64(p) {
+				continue
+			}
+			if !f(p) {
+				return
+			}
+		}
+		if hi-lo <= 2*n {
+			return
+		}
+		lo += 2 * n
+	}
+}
+
+// PrimesInRange returns all primes p with lo <= p < hi.
+func PrimesInRange(lo, hi uint64) (primes []uint64) {
+	Sieve(lo, hi, func(p uint64) bool {
+		primes = append(primes, p)
+		return true
+	})
+	return
+}
+
+// sievingPrimes returns the odd primes <= n < 2^22.
+func sievingPrimes(n uint64) []uint32 {
+	primes := primes16
+	if n >= 1<<16 {
+		primes22Once.Do(func() {
+			primes22 = eratosthenes(1 << sieveBaseBits)
+		})
+		primes = primes22
+	}
+	end := sort.Search(len(primes), func(i int) bool {
+		return uint64(primes[i]) > n
+	})
+	if end == 0 {
+		return nil
+	}
+	return primes[1:end]
+}
+
+// sqrt64 returns floor(sqrt(n)).
+func sqrt64(n uint64) uint64 {
+	r := uint64(math.Sqrt(float64(n)))
+	// float64 may be off by one either way
+	for r > 0 && (r > math.MaxUint32 || r*r > n) {
+		r--
+	}
+	for r < math.MaxUint32 && (r+1)*(r+1) <= n {
+		r++
+	}
+	return r
+}
@@ -0,0 +1,88 @@
+package prime
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSieve(t *testing.T) {
+	cases := []struct {
+		lo, hi uint64
+		count  int
+	}{
+		{0, 0, 0},
+		{0, 2, 0},
+		{0, 3, 1},
+		{2, 3, 1},
+		{3, 3, 0},
+		{0, 100, 25},
+		{1, 1 << 16, 6542},
+		{0, 1000000, 78498},
+		{0, 10000000, 664579},
+		{1000000, 2000000, 70435},
+	}
+	for _, c := range cases {
+		assert.Len(t, PrimesInRange(c.lo, c.hi), c.count, fmt.Sprintf("lo=%d, hi=%d", c.lo, c.hi))
+	}
+	assert.Equal(t, []uint64{2, 3, 5, 7, 11, 13}, PrimesInRange(0, 17))
+	assert.Equal(t, []uint64{17863, 17881}, PrimesInRange(17863, 17882))
+	// stop early
+	var first []uint64
+	Sieve(1000, 2000, func(p uint64) bool {
+		first = append(first, p)
+		return len(first) < 3
+	})
+	assert.Equal(t, []uint64{1009, 1013, 1019}, first)
+}
+
+func TestSieveLarge(t *testing.T) {
+	// compare with BPSW in windows around the sieving limits
+	// up to the largest primes below 2^64
+	cases := []uint64{
+		1 << 32,
+		1000000000000,
+		1<<44 - 50000,
+		1 << 50,
+		math.MaxUint64 - 100000,
+	}
+	for _, lo := range cases {
+		hi := lo + 100000
+		if hi < lo {
+			hi = math.MaxUint64
+		}
+		var want []uint64
+		for n := lo; n < hi; n++ {
+			if new(big.Int).SetUint64(n).ProbablyPrime(0) {
+				want = append(want, n)
+			}
+		}
+		require.Equal(t, want, PrimesInRange(lo, hi), fmt.Sprintf("lo=%d", lo))
+	}
+	// the largest prime below 2^64
+	assert.Equal(t, []uint64{math.MaxUint64 - 58}, PrimesInRange(math.MaxUint64-80, math.MaxUint64))
+}
+
+func TestIsPrime64(t *testing.T) {
+	for n := uint64(0); n < 100000; n++ {
+		require.Equal(t, new(big.Int).SetUint64(n).ProbablyPrime(0), isPrime64(n), fmt.Sprintf("n=%d", n))
+	}
+	cases := []struct {
+		n    uint64
+		want bool
+	}{
+		{2047, false},                // strong pseudoprime to base 2
+		{3215031751, false},          // strong pseudoprime to bases 2, 3, 5, 7
+		{3825123056546413051, false}, // strong pseudoprime to bases up to 37
+		{math.MaxUint64 - 58, true},
+		{4294967291 * 4294967279, false},
+		{1 << 63, false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, isPrime64(c.n), fmt.Sprintf("n=%d", c.n))
+	}
+}
@@ -0,0 +1,577 @@
+package prime
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"math/rand"
+	"runtime"
+	"sort"
+)
+
+// siqsParams is the factor base size and half width M of the
+// sieve interval [-M, M) for N of about bits bits, between
+// the rows the values are interpolated.
+var siqsParams = []struct {
+	bits, fb, M int
+}{
+	{64, 100, 1 << 14},
+	{96, 150, 1 << 14},
+	{128, 300, 1 << 14},
+	{160, 700, 1 << 14},
+	{192, 2000, 1 << 15},
+	{224, 4000, 3 << 14},
+	{256, 8000, 1 << 16},
+	{288, 15000, 3 << 15},
+	{320, 24000, 1 << 17},
+	{352, 30000, 1 << 17},
+}
+
+const (
+	// the large prime bound is this times the largest prime
+	// in the factor base
+	siqsLargeMultiplier = 128
+	// primes below this are not sieved with
+	siqsSmallPrime = 32
+	// how many bits of g(x) may be missing from the sieve,
+	// for the primes and prime powers which are not sieved
+	// and the rounding of the logs
+	siqsFudge = 16
+	// extra relations beyond the size of the factor base
+	siqsExtra = 64
+	// rounds of siqsExtra more relations to try when
+	// every square is trivial before giving up
+	siqsRetries = 8
+)
+
+// SIQS looks for a factor of N with the self-initializing
+// quadratic sieve. It finds X and Y with X^2 = Y^2 mod N from
+// values of polynomials (Ax + B)^2 - kN which factor over the
+// small primes, allowing one larger prime, so the time depends
+// only on the size of N. It is the method of choice for N of
+// about 40 to 100 digits whose factors are too large for ECM.
+// It returns nil if N is prime and a root of N if it is a
+// perfect power. N below 2^64 is split with Pollard rho.
+//
+// See Contini, "Factoring integers with the self-initializing
+// quadratic sieve" (1997).
+func SIQS(N *big.Int) *big.Int {
+	d, _ := SIQSContext(context.Background(), N)
+	return d
+}
+
+// SIQSContext is SIQS but checks ctx between polynomials
+// and stops with ctx.Err() once it is done.
+func SIQSContext(ctx context.Context, N *big.Int) (*big.Int, error) {
+	return SIQSParallel(ctx, N, 1)
+}
+
+// SIQSParallel is SIQSContext with the sieving done by a pool
+// of workers, each with its own polynomials. If workers <= 0
+// it uses GOMAXPROCS.
+func SIQSParallel(ctx context.Context, N *big.Int, workers int) (*big.Int, error) {
+	// Step 0: parse input / easy cases
+	if N.Cmp(big.NewInt(4)) < 0 || BPSW(N) != IsComposite {
+		return nil, nil
+	}
+	if N.Bit(0) == 0 {
+		return big.NewInt(2), nil
+	}
+	// every square mod a prime power is trivial,
+	// and N is odd so any root of it is at least 3
+	if a, _, err := factorPower(ctx, N, 1); a != nil || err != nil {
+		return a, err
+	}
+	if N.BitLen() <= 64 {
+		for c := int64(1); ; c++ {
+			d, err := brentRhoContext(ctx, N, c, math.MaxInt)
+			if d != nil || err != nil {
+				return d, err
+			}
+		}
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// Step 1: the multiplier and the factor base
+	s, d := newSIQS(N)
+	if d != nil {
+		return d, nil
+	}
+
+	// Step 2: sieve until there are more relations
+	// than primes, then look for a square
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	found := make(chan siqsRelation, 64*workers)
+	for w := 0; w < workers; w++ {
+		go s.work(ctx, int64(w), found)
+	}
+	rs := newRelations(N)
+	need := len(s.fb) + siqsExtra
+	for retries := 0; ; {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case r := <-found:
+			rs.add(r.relation, r.large)
+		}
+		if len(rs.full) < need {
+			continue
+		}
+		if d := combineSquares(N, s.fb, rs.full); d != nil {
+			return d, nil
+		}
+		// every square was trivial, so find some more, each
+		// of which works at least half the time once N has
+		// two different prime factors
+		if retries++; retries > siqsRetries {
+			return nil, fmt.Errorf("prime: SIQS found only trivial squares for %d", N)
+		}
+		need += siqsExtra
+	}
+}
+
+// siqsRelation is a relation found by a worker
+// with its large prime, if it has one.
+type siqsRelation struct {
+	relation
+	large uint64
+}
+
+// siqs is the data shared by the workers.
+type siqs struct {
+	N, kN *big.Int
+	// the factor base, fb[0] = 1 stands for -1 and fb[1] = 2,
+	// the rest are odd primes p with kN a square mod p
+	fb    []uint32
+	sqrt  []uint32 // sqrt(kN) mod p
+	logs  []uint8  // log2(p) rounded
+	M     int
+	large uint64
+	// the sieve value which makes x a candidate
+	threshold uint8
+	// A is the product of s primes from fb[lo:hi]
+	s, lo, hi int
+	logA      float64
+}
+
+// newSIQS chooses a multiplier k and builds the factor base
+// for kN. If it finds a factor of N on the way it returns it.
+func newSIQS(N *big.Int) (*siqs, *big.Int) {
+	// Step 1: parameters for the size of N
+	fbSize, M := siqsParams[0].fb, siqsParams[0].M
+	for i, p := range siqsParams {
+		if N.BitLen() <= p.bits {
+			if i > 0 {
+				q := siqsParams[i-1]
+				t := float64(N.BitLen()-q.bits) / float64(p.bits-q.bits)
+				fbSize = q.fb + int(t*float64(p.fb-q.fb))
+				M = q.M + int(t*float64(p.M-q.M))
+			}
+			break
+		}
+		fbSize, M = p.fb, p.M
+	}
+	k := siqsMultiplier(N)
+	s := &siqs{N: N, kN: new(big.Int).Mul(N, new(big.Int).SetUint64(k)), M: M}
+
+	// Step 2: the primes p with kN a square mod p
+	s.fb = []uint32{1, 2}
+	s.sqrt = []uint32{0, 0}
+	s.logs = []uint8{0, 1}
+	z, P := new(big.Int), new(big.Int)
+	for p := uint64(3); len(s.fb) < fbSize; p += 2 {
+		if !isPrime64(p) {
+			continue
+		}
+		r := z.Mod(s.kN, P.SetUint64(p)).Uint64()
+		if r == 0 && k%p != 0 {
+			return nil, new(big.Int).SetUint64(p)
+		}
+		if r != 0 && powMod64(r, (p-1)/2, p) != 1 {
+			continue
+		}
+		s.fb = append(s.fb, uint32(p))
+		s.sqrt = append(s.sqrt, uint32(sqrtMod64(r, p)))
+		s.logs = append(s.logs, uint8(math.Round(math.Log2(float64(p)))))
+	}
+	pmax := uint64(s.fb[len(s.fb)-1])
+	s.large = min(siqsLargeMultiplier*pmax, pmax*pmax)
+
+	// Step 3: x is a candidate when the logs of the primes
+	// sieved add up to within a large prime and the fudge of
+	// log2|g(x)| <= log2(M sqrt(kN/2))
+	logg := math.Log2(float64(M)) + log2Big(s.kN)/2 - 0.5
+	s.threshold = uint8(max(0, math.Round(logg-math.Log2(float64(s.large))-siqsFudge)))
+
+	// Step 4: A = sqrt(2kN)/M is a product of s primes of
+	// about 2^11 but at most pmax/2, or fewer if that leaves
+	// too few to choose
+	s.logA = log2Big(s.kN)/2 + 0.5 - math.Log2(float64(M))
+	s.s = max(1, int(math.Round(s.logA/11)))
+	for math.Exp2(s.logA/float64(s.s)) > float64(pmax)/2 {
+		s.s++
+	}
+	small := 2
+	for small < len(s.fb) && s.fb[small] < siqsSmallPrime {
+		small++
+	}
+	for {
+		q := math.Exp2(s.logA / float64(s.s))
+		s.lo = sort.Search(len(s.fb), func(i int) bool { return float64(s.fb[i]) >= q/2 })
+		s.hi = sort.Search(len(s.fb), func(i int) bool { return float64(s.fb[i]) > 2*q })
+		s.lo = max(s.lo, small)
+		if s.hi-s.lo >= 2*s.s+4 || s.s == 1 || math.Exp2(s.logA/float64(s.s-1)) > float64(pmax)/2 {
+			break
+		}
+		s.s--
+	}
+	if s.hi-s.lo < s.s+1 {
+		// there are not enough primes of the right size,
+		// so take the nearest which are sieved
+		s.hi = min(len(s.fb), s.lo+2*s.s+4)
+		s.lo = max(small, s.hi-2*s.s-4)
+	}
+	return s, nil
+}
+
+// work sieves polynomials with A chosen using seed
+// and sends the relations it finds until ctx is done.
+func (s *siqs) work(ctx context.Context, seed int64, found chan<- siqsRelation) {
+	random := rand.New(rand.NewSource(seed))
+	n := len(s.fb)
+	M := s.M
+	sieve := make([]uint8, 2*M)
+	ainv := make([]uint32, n)
+	r1 := make([]uint32, n)
+	r2 := make([]uint32, n)
+	inA := make([]bool, n)
+	Bainv := make([][]uint32, s.s)
+	for l := range Bainv {
+		Bainv[l] = make([]uint32, n)
+	}
+	Mmod := make([]uint32, n)
+	fbBig := make([]*big.Int, n)
+	for i := 1; i < n; i++ {
+		Mmod[i] = uint32(uint64(M) % uint64(s.fb[i]))
+		fbBig[i] = big.NewInt(int64(s.fb[i]))
+	}
+	A := new(big.Int)
+	B := new(big.Int)
+	Bl := make([]*big.Int, s.s)
+	var qs []uint32
+	z := new(big.Int)
+	// the sieve starts at base, so a byte >= cut is a candidate
+	base := uint8(max(0, 128-int(s.threshold)))
+	cut := base + s.threshold
+	empty := make([]uint8, 2*M)
+	for i := range empty {
+		empty[i] = base
+	}
+
+	// trial divides g(x) = ((Ax + B)^2 - kN)/A by the primes
+	// of A and those with x a root mod p
+	Y, v, q, r := new(big.Int), new(big.Int), new(big.Int), new(big.Int)
+	trial := func(x int64) (siqsRelation, bool) {
+		Y.Mul(A, q.SetInt64(x))
+		Y.Add(Y, B)
+		v.Mul(Y, Y)
+		v.Sub(v, s.kN)
+		v.Quo(v, A)
+		if v.Sign() == 0 {
+			return siqsRelation{}, false
+		}
+		factors := make([]uint32, 0, 32)
+		if v.Sign() < 0 {
+			factors = append(factors, 0)
+			v.Neg(v)
+		}
+		factors = append(factors, qs...)
+		for v.Bit(0) == 0 {
+			factors = append(factors, 1)
+			v.Rsh(v, 1)
+		}
+		for i := 2; i < n; i++ {
+			p := int64(s.fb[i])
+			if !inA[i] {
+				xp := uint32((x%p + p) % p)
+				if xp != r1[i] && xp != r2[i] {
+					continue
+				}
+			}
+			for {
+				q.QuoRem(v, fbBig[i], r)
+				if r.Sign() != 0 {
+					break
+				}
+				v.Set(q)
+				factors = append(factors, uint32(i))
+			}
+		}
+		if !v.IsUint64() || v.Uint64() > s.large {
+			return siqsRelation{}, false
+		}
+		return siqsRelation{relation{X: new(big.Int).Mod(Y, s.N), factors: factors}, v.Uint64()}, true
+	}
+
+	for ctx.Err() == nil {
+		// Step 1: A = q_1 ... q_s and B = B_1 + ... + B_s with
+		// B_l = (A/q_l) (sqrt(kN) (A/q_l)^-1 mod q_l) so that
+		// B^2 = kN mod A
+		qs = s.chooseA(random)
+		A.SetInt64(1)
+		for _, i := range qs {
+			A.Mul(A, fbBig[i])
+			inA[i] = true
+		}
+		B.SetInt64(0)
+		for l, i := range qs {
+			p := uint64(s.fb[i])
+			Aq := new(big.Int).Quo(A, fbBig[i])
+			g := mulMod64(uint64(s.sqrt[i]), modInverse64(z.Mod(Aq, fbBig[i]).Uint64(), p), p)
+			g = min(g, p-g)
+			Bl[l] = Aq.Mul(Aq, z.SetUint64(g))
+			B.Add(B, Bl[l])
+		}
+
+		// Step 2: the roots of (Ax + B)^2 = kN mod p are
+		// (+-sqrt(kN) - B)/A and when B changes by 2B_l they
+		// change by 2B_l/A
+		for i := 2; i < n; i++ {
+			if inA[i] {
+				continue
+			}
+			p := uint64(s.fb[i])
+			ainv[i] = uint32(modInverse64(z.Mod(A, fbBig[i]).Uint64(), p))
+			b := z.Mod(B, fbBig[i]).Uint64()
+			t := uint64(s.sqrt[i])
+			r1[i] = uint32(mulMod64(uint64(ainv[i]), (t+p-b)%p, p))
+			r2[i] = uint32(mulMod64(uint64(ainv[i]), (2*p-t-b)%p, p))
+			for l := range Bl {
+				Bainv[l][i] = uint32(mulMod64(2*z.Mod(Bl[l], fbBig[i]).Uint64()%p, uint64(ainv[i]), p))
+			}
+		}
+
+		// Step 3: the 2^(s-1) choices of signs for B_1, ..., B_(s-1)
+		// in Gray code order, each one changing a single sign
+		for j := 0; j < 1<<(s.s-1); j++ {
+			if j > 0 {
+				l := bits.TrailingZeros(uint(j))
+				// the sign of B_l flips, to - if bit l of
+				// the Gray code is now set
+				minus := (j^j>>1)>>l&1 == 1
+				z.Lsh(Bl[l], 1)
+				if minus {
+					B.Sub(B, z)
+				} else {
+					B.Add(B, z)
+				}
+				for i := 2; i < n; i++ {
+					if inA[i] {
+						continue
+					}
+					p := s.fb[i]
+					d := Bainv[l][i]
+					if !minus {
+						d = p - d
+					}
+					r1[i] = uint32((uint64(r1[i]) + uint64(d)) % uint64(p))
+					r2[i] = uint32((uint64(r2[i]) + uint64(d)) % uint64(p))
+				}
+			}
+
+			// Step 4: add log2(p) at every x in [-M, M) which
+			// is a root mod p, for all but the smallest p
+			copy(sieve, empty)
+			for i := 2; i < n; i++ {
+				p := s.fb[i]
+				if p < siqsSmallPrime || inA[i] {
+					continue
+				}
+				lp := s.logs[i]
+				a := int((r1[i] + Mmod[i]) % p)
+				b := int((r2[i] + Mmod[i]) % p)
+				for x := a; x < len(sieve); x += int(p) {
+					sieve[x] += lp
+				}
+				if b != a {
+					for x := b; x < len(sieve); x += int(p) {
+						sieve[x] += lp
+					}
+				}
+			}
+
+			// Step 5: the sieve starts at base so that the
+			// candidates are the bytes >= 128 + threshold - base,
+			// which all have their top bit set, 8 at a time
+			for at := 0; at < len(sieve); at += 8 {
+				if binary.LittleEndian.Uint64(sieve[at:])&0x8080808080808080 == 0 {
+					continue
+				}
+				for k := at; k < at+8; k++ {
+					if sieve[k] < cut {
+						continue
+					}
+					rel, ok := trial(int64(k - M))
+					if !ok {
+						continue
+					}
+					select {
+					case found <- rel:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+		for _, i := range qs {
+			inA[i] = false
+		}
+	}
+}
+
+// chooseA returns the indices of s primes from fb[lo:hi]
+// whose product is close to 2^logA, the last one chosen to
+// make up the difference.
+func (s *siqs) chooseA(random *rand.Rand) []uint32 {
+	for {
+		qs := make([]uint32, 0, s.s)
+		used := make(map[uint32]bool)
+		logA := s.logA
+		for len(qs) < s.s-1 {
+			i := uint32(s.lo + random.Intn(s.hi-s.lo))
+			if used[i] || s.sqrt[i] == 0 {
+				continue
+			}
+			used[i] = true
+			qs = append(qs, i)
+			logA -= math.Log2(float64(s.fb[i]))
+		}
+		// the prime closest to what is left
+		want := math.Exp2(logA)
+		i := sort.Search(len(s.fb), func(i int) bool { return float64(s.fb[i]) >= want })
+		if i > 2 && (i == len(s.fb) || want-float64(s.fb[i-1]) < float64(s.fb[i])-want) {
+			i--
+		}
+		for i < len(s.fb) && (used[uint32(i)] || s.fb[i] < siqsSmallPrime || s.sqrt[i] == 0) {
+			i++
+		}
+		if i < len(s.fb) {
+			return append(qs, uint32(i))
+		}
+	}
+}
+
+// siqsMultiplier returns the small k which makes the most
+// small primes have kN a square mod p, weighted by how often
+// they divide values of the polynomials.
+//
+// See Silverman, "The multiple polynomial quadratic sieve"
+// (1987), on the Knuth-Schroeppel function.
+func siqsMultiplier(N *big.Int) uint64 {
+	return siqsMultipliers(N)[0]
+}
+
+// siqsMultipliers returns the small k of siqsMultiplier,
+// best first.
+func siqsMultipliers(N *big.Int) []uint64 {
+	ks := []uint64{1, 3, 5, 7, 11, 13, 15, 17, 19, 21, 23, 29, 31, 33, 35, 37, 39, 41, 43, 47, 51, 53, 55, 57, 59, 61, 65, 67, 69, 71, 73}
+	scores := make(map[uint64]float64, len(ks))
+	z, P := new(big.Int), new(big.Int)
+	n8 := N.Uint64() & 7
+	for _, k := range ks {
+		score := -0.5 * math.Log(float64(k))
+		switch k * n8 & 7 {
+		case 1:
+			score += 2 * math.Ln2
+		case 5:
+			score += math.Ln2
+		default:
+			score += 0.5 * math.Ln2
+		}
+		for _, p := range primes10[1:] {
+			p := uint64(p)
+			r := z.Mod(N, P.SetUint64(p)).Uint64() * k % p
+			switch {
+			case r == 0:
+				score += math.Log(float64(p)) / float64(p)
+			case powMod64(r, (p-1)/2, p) == 1:
+				score += 2 * math.Log(float64(p)) / float64(p-1)
+			}
+		}
+		scores[k] = score
+	}
+	sort.SliceStable(ks, func(i, j int) bool { return scores[ks[i]] > scores[ks[j]] })
+	return ks
+}
+
+// sqrtMod64 returns a square root of a mod the odd prime p,
+// with the Tonelli-Shanks algorithm, if a is a square mod p.
+func sqrtMod64(a, p uint64) uint64 {
+	a %= p
+	if a == 0 {
+		return 0
+	}
+	if p&3 == 3 {
+		return powMod64(a, (p+1)/4, p)
+	}
+	// p - 1 = q 2^e with q odd and z a non-square
+	q := p - 1
+	e := bits.TrailingZeros64(q)
+	q >>= e
+	z := uint64(2)
+	for powMod64(z, (p-1)/2, p) != p-1 {
+		z++
+	}
+	c := powMod64(z, q, p)
+	x := powMod64(a, (q+1)/2, p)
+	t := powMod64(a, q, p)
+	for t != 1 {
+		// the least i with t^(2^i) = 1
+		i, t2 := 0, t
+		for t2 != 1 {
+			t2 = mulMod64(t2, t2, p)
+			i++
+		}
+		b := c
+		for j := 0; j < e-i-1; j++ {
+			b = mulMod64(b, b, p)
+		}
+		x = mulMod64(x, b, p)
+		c = mulMod64(b, b, p)
+		t = mulMod64(t, c, p)
+		e = i
+	}
+	return x
+}
+
+// modInverse64 returns a^-1 mod n for a coprime to n.
+func modInverse64(a, n uint64) uint64 {
+	// extended Euclid keeping only the coefficient of a
+	t, newt := int64(0), int64(1)
+	r, newr := n, a%n
+	for newr != 0 {
+		q := r / newr
+		t, newt = newt, t-int64(q)*newt
+		r, newr = newr, r-q*newr
+	}
+	if t < 0 {
+		t += int64(n)
+	}
+	return uint64(t)
+}
+
+// log2Big returns log2(x) for x > 0.
+func log2Big(x *big.Int) float64 {
+	n := x.BitLen()
+	if n <= 64 {
+		return math.Log2(float64(x.Uint64()))
+	}
+	top := new(big.Int).Rsh(x, uint(n-64)).Uint64()
+	return float64(n-64) + math.Log2(float64(top))
+}
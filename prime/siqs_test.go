@@ -0,0 +1,73 @@
+package prime
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSIQS(t *testing.T) {
+	parse := func(s string) *big.Int {
+		n, _ := new(big.Int).SetString(s, 10)
+		return n
+	}
+	cases := []struct {
+		p, q *big.Int
+	}{
+		{big.NewInt(65537), big.NewInt(65539)},
+		{big.NewInt(2147483647), big.NewInt(4294967291)},
+		{big.NewInt(1<<40 - 87), big.NewInt(1<<40 + 15)},
+		{big.NewInt(4294967291), rough62},
+		{parse("22101405602880366353"), parse("22930906686002846147")},
+		{parse("659696734151425640607059"), parse("872680022898995997155213")},
+	}
+	for _, c := range cases {
+		N := new(big.Int).Mul(c.p, c.q)
+		d := SIQS(N)
+		require.NotNil(t, d, fmt.Sprintf("N=%d", N))
+		assert.True(t, d.Cmp(c.p) == 0 || d.Cmp(c.q) == 0, fmt.Sprintf("N=%d, d=%d", N, d))
+	}
+	assert.Nil(t, SIQS(rough64))
+	assert.Equal(t, big.NewInt(2), SIQS(big.NewInt(1<<40)))
+	assert.Equal(t, rough62, SIQS(new(big.Int).Mul(rough62, rough62)))
+	// higher powers, whose squares would all be trivial
+	pow := func(p *big.Int, k int64) *big.Int { return new(big.Int).Exp(p, big.NewInt(k), nil) }
+	assert.Equal(t, big.NewInt(4194319), SIQS(pow(big.NewInt(4194319), 3)))
+	assert.Equal(t, rough62, SIQS(pow(rough62, 3)))
+	assert.Equal(t, big.NewInt(3), SIQS(pow(big.NewInt(3), 101)))
+	// three factors
+	N := new(big.Int).Mul(big.NewInt(2147483647), new(big.Int).Mul(big.NewInt(4294967291), rough62))
+	d := SIQS(N)
+	require.NotNil(t, d)
+	assert.Equal(t, 0, new(big.Int).Mod(N, d).Sign())
+	assert.True(t, d.Cmp(one) == 1 && d.Cmp(N) == -1, fmt.Sprintf("d=%d", d))
+}
+
+func TestSIQSParallel(t *testing.T) {
+	p, _ := new(big.Int).SetString("22101405602880366353", 10)
+	q, _ := new(big.Int).SetString("22930906686002846147", 10)
+	N := new(big.Int).Mul(p, q)
+	d, err := SIQSParallel(context.Background(), N, 3)
+	require.NoError(t, err)
+	assert.True(t, d.Cmp(p) == 0 || d.Cmp(q) == 0, fmt.Sprintf("d=%d", d))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = SIQSContext(ctx, N)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestSqrtMod64(t *testing.T) {
+	for _, p := range []uint64{3, 5, 7, 13, 17, 41, 97, 65537, 1<<31 - 1, 4294967291} {
+		for a := uint64(0); a < 200; a++ {
+			r := sqrtMod64(a*a%p, p)
+			assert.Equal(t, a*a%p, mulMod64(r, r, p), fmt.Sprintf("a=%d, p=%d", a, p))
+			if a%p != 0 {
+				assert.Equal(t, uint64(1), mulMod64(a%p, modInverse64(a, p), p), fmt.Sprintf("a=%d, p=%d", a, p))
+			}
+		}
+	}
+}
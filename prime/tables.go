@@ -3,18 +3,6 @@ package prime
 import "math/big"
 
 var (
-	// diffs to next relatively prime number mod 210 = 2*3*5*7
-	// so gcd(210,diff210[i] + i) = 1
-	diffs210 = []int{
-		11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 2, 1, 4, 3, 2, 1, 2, 1, 4, 3, 2, 1, 6,
-		5, 4, 3, 2, 1, 2, 1, 6, 5, 4, 3, 2, 1, 4, 3, 2, 1, 2, 1, 4, 3, 2, 1, 6, 5,
-		4, 3, 2, 1, 6, 5, 4, 3, 2, 1, 2, 1, 6, 5, 4, 3, 2, 1, 4, 3, 2, 1, 2, 1, 6,
-		5, 4, 3, 2, 1, 4, 3, 2, 1, 6, 5, 4, 3, 2, 1, 8, 7, 6, 5, 4, 3, 2, 1, 4, 3,
-		2, 1, 2, 1, 4, 3, 2, 1, 2, 1, 4, 3, 2, 1, 8, 7, 6, 5, 4, 3, 2, 1, 6, 5, 4,
-		3, 2, 1, 4, 3, 2, 1, 6, 5, 4, 3, 2, 1, 2, 1, 4, 3, 2, 1, 6, 5, 4, 3, 2, 1,
-		2, 1, 6, 5, 4, 3, 2, 1, 6, 5, 4, 3, 2, 1, 4, 3, 2, 1, 2, 1, 4, 3, 2, 1, 6,
-		5, 4, 3, 2, 1, 2, 1, 6, 5, 4, 3, 2, 1, 4, 3, 2, 1, 2, 1, 4, 3, 2, 1, 2, 1,
-		10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 2}
 	// all primes < 10 bits long
 	primes10 = []uint16{
 		2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61,
@@ -29,6 +17,8 @@ var (
 		743, 751, 757, 761, 769, 773, 787, 797, 809, 811, 821, 823, 827, 829,
 		839, 853, 857, 859, 863, 877, 881, 883, 887, 907, 911, 919, 929, 937,
 		941, 947, 953, 967, 971, 977, 983, 991, 997, 1009, 1013, 1019, 1021}
+	// all primes < 16 bits long, sieved at init
+	primes16 = eratosthenes(1 << 16)
 	// all primes < 10 bits and their product
 	prodPrimes10A, _ = new(big.Int).SetString("24776ffd3cbd21c872eccd26ad078c5ba0586e2e57cf68515e3c4828a673a6e", 16)
 	prodPrimes10B, _ = new(big.Int).SetString("b4aec292f0e79567889c96d7d7eca2aa680bc5727ba136196bc1c1826d0f8b905b973bbda3a499ec8ef236d53", 16)
@@ -2,6 +2,7 @@ package prime
 
 import (
 	"crypto/rand"
+	"io"
 	"math/big"
 	"sort"
 )
@@ -174,6 +175,35 @@ func StrongLucasSelfridge(N *big.Int) int {
 	// Step 4: Calculate the U's and V's
 	// return true if we have any of the equalities (mod N)
 	// U_d=0, V_d=0, V_2d=0, V_4d=0, V_8d=0,...,V_{2^(s-1)d}
+	Uk, Vk, Qk := lucasSequence(P, Q, D, d, N)
+	var tmp big.Int
+	// U_k, V_k, Q^k are now all with k=d
+	if Uk.Sign() == 0 {
+		// if U_d = 0
+		return Undetermined
+	}
+	// Now we look at powers V_{{2^r}d} for r = 0..s-1
+	var r uint
+	for r = 0; r < s; r++ {
+		if Vk.Sign() == 0 {
+			// if V_{2^rd} = 0
+			return Undetermined
+		}
+		Vk.Mul(Vk, Vk)
+		Vk.Sub(Vk, tmp.Lsh(Qk, 1))
+		Vk.Mod(Vk, N) // V_{2^{r+1}d}
+		Qk.Mul(Qk, Qk)
+		Qk.Mod(Qk, N) // Q_{2^(r+1)d}
+	}
+
+	// Step 5: return false because it didn't pass the test
+	return IsComposite
+}
+
+// lucasSequence returns U_k, V_k and Q^k mod N for the
+// Lucas sequences with parameters P, Q and D = P^2 - 4Q.
+// N must be odd since it divides by 2 mod N.
+func lucasSequence(P, Q, D, k, N *big.Int) (Uk, Vk, Qk *big.Int) {
 	divideBy2ModN := func(x *big.Int) *big.Int {
 		if x.Bit(0) != 0 {
 			x.Add(x, N)
@@ -181,11 +211,11 @@ func StrongLucasSelfridge(N *big.Int) int {
 		return x.Rsh(x, 1)
 	}
 	var tmp, PxUk, DxUk, PxVk big.Int
-	Uk := big.NewInt(0)         // U_0 = 0
-	Vk := new(big.Int).Set(two) // V_0 = 2
-	Qk := new(big.Int).Set(one) // Q^0 = 1
+	Uk = big.NewInt(0)         // U_0 = 0
+	Vk = new(big.Int).Set(two) // V_0 = 2
+	Qk = new(big.Int).Set(one) // Q^0 = 1
 	// follow repeated squaring algorithm
-	for i := d.BitLen() - 1; i > -1; i-- {
+	for i := k.BitLen() - 1; i > -1; i-- {
 		// double everything
 		Uk.Mul(Uk, Vk)
 		Uk.Mod(Uk, N) // now U_{2k}
@@ -194,7 +224,7 @@ func StrongLucasSelfridge(N *big.Int) int {
 		Vk.Mod(Vk, N) // now V_{2k}
 		Qk.Mul(Qk, Qk)
 		Qk.Mod(Qk, N) // now Q^{2k}
-		if d.Bit(i) == 1 {
+		if k.Bit(i) == 1 {
 			// if bit is set then increment by 1
 			Qk.Mul(Qk, Q)
 			Qk.Mod(Qk, N) // now Q^{2k+1}
@@ -208,27 +238,7 @@ func StrongLucasSelfridge(N *big.Int) int {
 			Vk.Mod(divideBy2ModN(tmp.Add(&DxUk, &PxVk)), N) // now V_{2k+1}
 		}
 	}
-	// U_k, V_k, Q^k are now all with k=d
-	if Uk.Sign() == 0 {
-		// if U_d = 0
-		return Undetermined
-	}
-	// Now we look at powers V_{{2^r}d} for r = 0..s-1
-	var r uint
-	for r = 0; r < s; r++ {
-		if Vk.Sign() == 0 {
-			// if V_{2^rd} = 0
-			return Undetermined
-		}
-		Vk.Mul(Vk, Vk)
-		Vk.Sub(Vk, tmp.Lsh(Qk, 1))
-		Vk.Mod(Vk, N) // V_{2^{r+1}d}
-		Qk.Mul(Qk, Qk)
-		Qk.Mod(Qk, N) // Q_{2^(r+1)d}
-	}
-
-	// Step 5: return false because it didn't pass the test
-	return IsComposite
+	return
 }
 
 // SolovayStrassen chooses k random numbers in [2,...,N]
@@ -238,19 +248,31 @@ func StrongLucasSelfridge(N *big.Int) int {
 // See https://en.wikipedia.org/wiki/Solovay%E2%80%93Strassen_primality_test.
 // Probability it passes and is not prime is 2^(-k).
 func SolovayStrassen(N *big.Int, k int) int {
+	result, err := SolovayStrassenFrom(rand.Reader, N, k)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// SolovayStrassenFrom is SolovayStrassen with
+// the random numbers read from random.
+func SolovayStrassenFrom(random io.Reader, N *big.Int, k int) (int, error) {
 	if N.Bit(0) == 0 && N.BitLen() > 1 {
-		return IsComposite
+		return IsComposite, nil
 	}
 	a := new(big.Int)
 	b := make([]byte, N.BitLen())
 	for i := 0; i < k; i++ {
-		rand.Read(b)
+		if _, err := io.ReadFull(random, b); err != nil {
+			return Undetermined, err
+		}
 		a.SetBytes(b)
 		if basedSolovayStrassen(N, a) == IsComposite {
-			return IsComposite
+			return IsComposite, nil
 		}
 	}
-	return Undetermined
+	return Undetermined, nil
 }
 
 func basedSolovayStrassen(N, a *big.Int) int {
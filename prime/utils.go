@@ -1,8 +1,12 @@
 package prime
 
 import (
+	"context"
+	"fmt"
 	"math"
 	"math/big"
+	"math/bits"
+	"time"
 )
 
 // JacobiSymbol returns the jacobi symbol ( N / D ) of
@@ -135,70 +139,354 @@ func IsSquare(N *big.Int) bool {
 	}
 }
 
-type factorization = map[*big.Int]uint64
-
-func factor(N *big.Int) factorization {
-	F := make(factorization)
-	// Find power of 2 dividing F
-	var e int64
-	for ; N.Bit(int(e)) == 0; e++ {
-		count, _ := F[two]
-		F[two] = count + 1
-	}
-	N.Rsh(N, uint(e))
-	// Find upper limit
-	s := new(big.Int)
-	s.Sqrt(N)
-	p := big.NewInt(3)
-	// while p < s and N > 1
-	q := new(big.Int)
-	r := new(big.Int)
-	for p.Cmp(s) != 1 && N.Cmp(one) == 1 {
-		for e = 1; ; e++ {
-			q.QuoRem(N, p, r)
-			if r.BitLen() == 0 {
-				N.Set(q)
-				s.Sqrt(N)
-				F[p] = uint64(e)
-			} else {
-				break
-			}
+const (
+	// bounds for the p-1 and p+1 stages of Factor
+	factorB1 = 1000
+	factorB2 = 100000
+	// above this many bits rho only gets factorRhoLimit
+	// iterations before the cofactor goes to ECM and SIQS
+	factorSIQSBits = 80
+	factorRhoLimit = 1 << 14
+	// above this many bits SIQS would take days, so
+	// ECM runs until it finds something instead
+	factorSIQSMaxBits = 330
+)
+
+// factorECMLevels are the B1 bounds and numbers of curves
+// which find most factors with the given number of digits,
+// from the table in the documentation of ECM.
+var factorECMLevels = []struct {
+	digits int
+	B1     uint64
+	curves int
+}{
+	{15, 2000, 25},
+	{20, 11000, 90},
+	{25, 50000, 300},
+	{30, 250000, 700},
+	{35, 1000000, 1800},
+	{40, 3000000, 5100},
+	{45, 11000000, 10600},
+	{50, 43000000, 19300},
+}
+
+// Factor returns the prime factorization of |N|, which is
+// empty for N = 0 and N = +-1, without changing N. The
+// primes above 2^64 are probable primes as in BPSW.
+func Factor(N *big.Int) Factorization {
+	F, _ := FactorContext(context.Background(), N)
+	return F
+}
+
+// FactorContext is Factor but checks ctx in every method
+// and stops with ctx.Err() once it is done. It is
+// FactorWithOptions with the defaults and one worker.
+func FactorContext(ctx context.Context, N *big.Int) (Factorization, error) {
+	return FactorWithOptions(ctx, N, &FactorOptions{Workers: 1})
+}
+
+// FactorOptions tune FactorWithOptions. The zero value
+// uses the defaults.
+type FactorOptions struct {
+	// B1 and B2 are the bounds for p-1 and p+1, and for ECM
+	// if ECM is set [default: 1000 and 100 B1]
+	B1, B2 uint64
+	// ECM replaces the ECM schedule and SIQS by ECM with B1
+	// and B2 for up to Curves curves, 0 for no limit
+	ECM    bool
+	Curves int
+	// Workers run the curves of ECM and the polynomials of
+	// SIQS in parallel, GOMAXPROCS if it is not positive
+	Workers int
+	// Progress, if not nil, is told each method as it starts
+	// and each factor found
+	Progress func(string)
+}
+
+// FactorWithOptions is FactorContext with the methods chosen
+// by opts, which may be nil. The primes below 2^16 are found by
+// trial division and each cofactor is split until BPSW says it
+// is a (probable) prime. Anything below 2^64 is left to Factor64
+// and perfect powers are split by taking roots. The rest gets
+// p-1, p+1 and Pollard rho, forever for up to factorSIQSBits
+// bits and otherwise only briefly before ECM with the bounds for
+// factors of up to 4/13 of its digits and then SIQS, or above
+// factorSIQSMaxBits bits ECM until it finds a factor. So the
+// time depends on the size of the second largest prime factor
+// of N, or for the larger ones on the size of the cofactor,
+// rather than on sqrt(N).
+func FactorWithOptions(ctx context.Context, N *big.Int, opts *FactorOptions) (Factorization, error) {
+	o := FactorOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.B1 == 0 {
+		o.B1 = factorB1
+	}
+	if o.B2 == 0 {
+		o.B2 = 100 * o.B1
+	}
+
+	// Step 1: trial divide by the primes below 2^16
+	if n := new(big.Int).Abs(N); n.IsUint64() {
+		F := Factorization{}
+		F.add64(n.Uint64(), 1)
+		return F, nil
+	}
+	F, r := trialDivide(N)
+	if r.Sign() == 0 {
+		return F, nil
+	}
+
+	// Step 2: split the cofactors n^e until they are all prime
+	stack := []PrimePower{{P: r, E: 1}}
+	for len(stack) > 0 {
+		n, e := stack[len(stack)-1].P, stack[len(stack)-1].E
+		stack = stack[:len(stack)-1]
+		if n.Cmp(one) == 0 {
+			continue
+		}
+		if n.IsUint64() {
+			F.add64(n.Uint64(), e)
+			continue
+		}
+		if BPSW(n) != IsComposite {
+			F.add(n, e)
+			continue
 		}
-		p = NextPrime(big.NewInt(0).Add(p, two))
+		a, k, err := factorPower(ctx, n, 16)
+		if err != nil {
+			return nil, err
+		}
+		if a != nil {
+			stack = append(stack, PrimePower{P: a, E: e * uint64(k)})
+			continue
+		}
+		o.progress("splitting a %d digit composite", len(n.String()))
+		start := time.Now()
+		d, method, err := o.split(ctx, n)
+		if err != nil {
+			return nil, err
+		}
+		o.progress("%s found %d after %v", method, d, time.Since(start).Round(time.Millisecond))
+		stack = append(stack, PrimePower{P: d, E: e}, PrimePower{P: new(big.Int).Quo(n, d), E: e})
 	}
-	if N.Cmp(one) == 1 {
-		F[N] = 1
+	return F, nil
+}
+
+// factorPower returns a and the least prime k < 2^10 with
+// n = a^k, or nil if there are none with a >= 2^minBits, so
+// k is at most the number of bits of n over minBits. It
+// stops with ctx.Err() between values of k once ctx is done.
+func factorPower(ctx context.Context, n *big.Int, minBits int) (*big.Int, int, error) {
+	if IsSquare(n) {
+		return new(big.Int).Sqrt(n), 2, nil
 	}
-	return F
+	for _, k := range primes10[1:] {
+		if int(k) > n.BitLen()/minBits {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		if a := binarySearchKthRoot(n, int(k)); a != nil {
+			return a, int(k), nil
+		}
+	}
+	return nil, 0, nil
 }
 
-func lcm(f1, f2 factorization) (f factorization) {
-	a1 := make(factorization)
-	a2 := make(factorization)
-	for p, e := range f1 {
-		a1[p] = e
-	}
-	for p, e := range f2 {
-		a2[p] = e
-	}
-	for p1, e1 := range f1 {
-		for p2, e2 := range f2 {
-			if p1.Cmp(p2) == 1 {
-				if e1 > e2 {
-					f[p1] = e1
-				} else {
-					f[p1] = e2
-				}
-				delete(a1, p1)
-				delete(a2, p2)
+// progress formats a message for o.Progress.
+func (o *FactorOptions) progress(format string, a ...any) {
+	if o.Progress != nil {
+		o.Progress(fmt.Sprintf(format, a...))
+	}
+}
+
+// split returns a factor of the composite n, which is not a
+// perfect power and has no factors below 2^16, and the name of the
+// method which found it.
+func (o *FactorOptions) split(ctx context.Context, n *big.Int) (*big.Int, string, error) {
+	// Step 1: factors p with p-1 or p+1 smooth
+	o.progress("p-1 with B1=%d, B2=%d", o.B1, o.B2)
+	if d, err := PMinus1Context(ctx, n, o.B1, o.B2); d != nil || err != nil {
+		return d, "p-1", err
+	}
+	o.progress("p+1 with B1=%d, B2=%d", o.B1, o.B2)
+	if d, err := PPlus1Context(ctx, n, o.B1, o.B2); d != nil || err != nil {
+		return d, "p+1", err
+	}
+
+	// Step 2: rho, which is quick for small factors and
+	// only fails if the cycles mod every prime line up,
+	// in which case it tries another polynomial
+	rho := func() (*big.Int, string, error) {
+		o.progress("rho")
+		for c := int64(1); ; c++ {
+			if d, err := brentRhoContext(ctx, n, c, math.MaxInt); d != nil || err != nil {
+				return d, "rho", err
 			}
 		}
-		if _, ok := a1[p1]; ok {
-			f[p1] = e1
+	}
+	if n.BitLen() <= factorSIQSBits {
+		return rho()
+	}
+	o.progress("rho for %d iterations", factorRhoLimit)
+	if d, err := brentRhoContext(ctx, n, 1, factorRhoLimit); d != nil || err != nil {
+		return d, "rho", err
+	}
+	if o.ECM {
+		o.progress("ECM with B1=%d, B2=%d", o.B1, o.B2)
+		d, err := ECMParallel(ctx, n, o.B1, o.B2, o.Curves, o.Workers)
+		if d == nil && err == nil {
+			err = fmt.Errorf("prime: ECM did not split %d in %d curves", n, o.Curves)
 		}
+		return d, "ECM", err
 	}
-	for p2, e2 := range a2 {
-		f[p2] = e2
+
+	// Step 3: ECM for factors small enough that it should
+	// find them faster than SIQS, or without end if n is
+	// too large for SIQS
+	digits := len(n.String())
+	siqs := n.BitLen() <= factorSIQSMaxBits
+	for i, level := range factorECMLevels {
+		if siqs && level.digits > 4*digits/13 {
+			break
+		}
+		curves := level.curves
+		if !siqs && i == len(factorECMLevels)-1 {
+			curves = 0
+		}
+		o.progress("ECM with B1=%d, B2=%d on %d curves for %d digit factors", level.B1, 100*level.B1, curves, level.digits)
+		if d, err := ECMParallel(ctx, n, level.B1, 100*level.B1, curves, o.Workers); d != nil || err != nil {
+			return d, "ECM", err
+		}
+	}
+
+	// Step 4: SIQS, whose time only depends on the size of n
+	o.progress("SIQS on %d digits", digits)
+	d, err := SIQSParallel(ctx, n, o.Workers)
+	return d, "SIQS", err
+}
+
+// add64 multiplies F by n^e.
+func (F *Factorization) add64(n, e uint64) {
+	P := new(big.Int)
+	for _, p := range Factor64(n) {
+		F.add(P.SetUint64(p), e)
+	}
+}
+
+// eratosthenes returns all primes < n
+// using the sieve of Eratosthenes.
+func eratosthenes(n int) (primes []uint32) {
+	composite := make([]bool, n)
+	for i := 2; i < n; i++ {
+		if composite[i] {
+			continue
+		}
+		primes = append(primes, uint32(i))
+		for j := i * i; j < n; j += i {
+			composite[j] = true
+		}
+	}
+	return
+}
+
+// trialDivide writes N = F*R where F is the factorization
+// of the part of N with prime factors < 2^16 and R has no such factors.
+func trialDivide(N *big.Int) (F Factorization, R *big.Int) {
+	F = Factorization{}
+	R = new(big.Int).Abs(N)
+	if R.Sign() == 0 {
+		return
+	}
+	z := new(big.Int)
+	P := new(big.Int)
+	// reduce mod products of 4 primes at a time
+	// since each product fits in a single word
+	for i := 0; i < len(primes16); i += 4 {
+		group := primes16[i:min(i+4, len(primes16))]
+		prod := uint64(1)
+		for _, p := range group {
+			prod *= uint64(p)
+		}
+		rem := z.Mod(R, P.SetUint64(prod)).Uint64()
+		for _, p := range group {
+			if rem%uint64(p) != 0 {
+				continue
+			}
+			P.SetUint64(uint64(p))
+			var e uint64
+			for z.Mod(R, P).Sign() == 0 {
+				R.Quo(R, P)
+				e++
+			}
+			F = append(F, PrimePower{P: big.NewInt(int64(p)), E: e})
+		}
 	}
 	return
 }
+
+// splitSmooth writes N = s*r where every prime
+// factor of s is < 2^16 and r has no such factors.
+func splitSmooth(N *big.Int) (s, r *big.Int) {
+	_, r = trialDivide(N)
+	if r.Sign() == 0 {
+		return big.NewInt(1), r
+	}
+	return new(big.Int).Quo(new(big.Int).Abs(N), r), r
+}
+
+// isPrime64 is a deterministic Miller-Rabin test for
+// n < 2^64 using the 7 bases found by Jim Sinclair.
+// See https://miller-rabin.appspot.com.
+func isPrime64(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+	for _, p := range primes10[:12] {
+		if n%uint64(p) == 0 {
+			return n == uint64(p)
+		}
+	}
+	d := n - 1
+	s := bits.TrailingZeros64(d)
+	d >>= s
+	for _, a := range []uint64{2, 325, 9375, 28178, 450775, 9780504, 1795265022} {
+		if a %= n; a == 0 {
+			continue
+		}
+		x := powMod64(a, d, n)
+		if x == 1 || x == n-1 {
+			continue
+		}
+		i := 1
+		for ; i < s && x != n-1; i++ {
+			x = mulMod64(x, x, n)
+		}
+		if x != n-1 {
+			return false
+		}
+	}
+	return true
+}
+
+// mulMod64 returns a*b mod n for a, b < n.
+func mulMod64(a, b, n uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	_, r := bits.Div64(hi, lo, n)
+	return r
+}
+
+// powMod64 returns a^e mod n for a < n.
+func powMod64(a, e, n uint64) uint64 {
+	r := uint64(1) % n
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			r = mulMod64(r, a, n)
+		}
+		a = mulMod64(a, a, n)
+	}
+	return r
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/tscholl2/prime/prime"
+)
+
+func rsaMain(args []string) {
+	fs := flag.NewFlagSet("rsa", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println(`prime rsa: generate an RSA private key and print it to stdout in PEM format
+Example: 'prime rsa -b 3072' prints a 3072 bit PKCS#1 key
+Example: 'prime rsa -b 4096 -n 3 -pkcs8' prints a 4096 bit PKCS#8 key with 3 primes
+Options:`)
+		fs.PrintDefaults()
+	}
+	var b, n int
+	var pkcs8 bool
+	var seed string
+	fs.IntVar(&b, "b", 2048, "number of bits in the modulus, at least 1024")
+	fs.IntVar(&n, "n", 2, "number of primes [supports: 2,3,...]")
+	fs.BoolVar(&pkcs8, "pkcs8", false, "output PKCS#8 instead of PKCS#1")
+	fs.StringVar(&seed, "seed", "", "seed for reproducible output, the same seed gives the same key")
+	fs.Parse(args)
+	key, err := prime.GenerateRSAKey(randomSource(seed), b, n)
+	if err != nil {
+		log.Fatal(err)
+	}
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if pkcs8 {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			log.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	pem.Encode(os.Stdout, block)
+}